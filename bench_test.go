@@ -0,0 +1,108 @@
+package itc
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// replicaCounts are the cluster sizes the core operations are benchmarked with.
+var replicaCounts = []int{16, 256, 4096}
+
+var clusters = map[int][]*Stamp{}
+
+// cluster returns the stamps of the given number of replicas that forked from a single seed and then
+// recorded events and synchronized with random other replicas for a few rounds, as in a long running
+// system. Clusters are built once per size and must not be modified by the benchmarks.
+func cluster(replicas int) []*Stamp {
+	if stamps, ok := clusters[replicas]; ok {
+		return stamps
+	}
+	r := rand.New(rand.NewSource(int64(replicas)))
+	stamps := []*Stamp{NewStamp()}
+	for len(stamps) < replicas {
+		stamps = append(stamps, stamps[r.Intn(len(stamps))].Fork())
+	}
+	for step := 0; step < 8*replicas; step++ {
+		s := stamps[r.Intn(len(stamps))]
+		if r.Intn(3) == 0 {
+			s.Join(stamps[r.Intn(len(stamps))].Peek())
+		} else {
+			s.Event()
+		}
+	}
+	clusters[replicas] = stamps
+	return stamps
+}
+
+// benchCluster runs bench for every cluster size with the stamps of its replicas.
+func benchCluster(b *testing.B, bench func(b *testing.B, stamps []*Stamp)) {
+	for _, replicas := range replicaCounts {
+		stamps := cluster(replicas)
+		b.Run(fmt.Sprintf("replicas=%d", replicas), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			bench(b, stamps)
+		})
+	}
+}
+
+func BenchmarkClusterEvent(b *testing.B) {
+	benchCluster(b, func(b *testing.B, stamps []*Stamp) {
+		for n := 0; n < b.N; n++ {
+			stamps[n%len(stamps)].WithEvent()
+		}
+	})
+}
+
+func BenchmarkClusterFork(b *testing.B) {
+	benchCluster(b, func(b *testing.B, stamps []*Stamp) {
+		for n := 0; n < b.N; n++ {
+			stamps[n%len(stamps)].Forked()
+		}
+	})
+}
+
+func BenchmarkClusterJoin(b *testing.B) {
+	benchCluster(b, func(b *testing.B, stamps []*Stamp) {
+		for n := 0; n < b.N; n++ {
+			if _, err := Joined(stamps[n%len(stamps)], stamps[(n+1)%len(stamps)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkClusterLEQ(b *testing.B) {
+	benchCluster(b, func(b *testing.B, stamps []*Stamp) {
+		for n := 0; n < b.N; n++ {
+			stamps[n%len(stamps)].LEQ(stamps[(n+1)%len(stamps)])
+		}
+	})
+}
+
+func BenchmarkClusterMarshalBinary(b *testing.B) {
+	benchCluster(b, func(b *testing.B, stamps []*Stamp) {
+		size := 0
+		for n := 0; n < b.N; n++ {
+			data, _ := stamps[n%len(stamps)].MarshalBinary()
+			size += len(data)
+		}
+		b.ReportMetric(float64(size)/float64(b.N), "bytes/stamp")
+	})
+}
+
+func BenchmarkClusterUnmarshalBinary(b *testing.B) {
+	benchCluster(b, func(b *testing.B, stamps []*Stamp) {
+		encoded := make([][]byte, len(stamps))
+		for i, s := range stamps {
+			encoded[i], _ = s.MarshalBinary()
+		}
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if err := (&Stamp{}).UnmarshalBinary(encoded[n%len(encoded)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
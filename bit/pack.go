@@ -6,6 +6,11 @@ import (
 	"fmt"
 )
 
+// Pusher is implemented by Pack and Writer, which both append bits to an encoding.
+type Pusher interface {
+	Push(value, size uint32)
+}
+
 type packEntry struct {
 	value, size uint32
 }
@@ -23,33 +28,39 @@ func NewPack() *Pack {
 func (p *Pack) Push(value, size uint32) {
 	p.entries = append(p.entries, packEntry{value: value, size: size})
 	freeBits := uint32(8*len(p.packed)) - p.bitLength
-	index := p.bitLength / 32
+	index := (p.bitLength / 32) * 4
 	if freeBits >= size {
 		shift := freeBits - size
 		v := binary.BigEndian.Uint32(p.packed[index:]) | (value << shift)
 		binary.BigEndian.PutUint32(p.packed[index:], v)
 	} else {
 		buf := make([]byte, 4)
-		low := value
+		spill := size - freeBits
 		if freeBits > 0 {
-			high := value>>size - freeBits
-			v := binary.BigEndian.Uint32(p.packed[index:]) | high
+			v := binary.BigEndian.Uint32(p.packed[index:]) | (value >> spill)
 			binary.BigEndian.PutUint32(p.packed[index:], v)
-			low = ((value << freeBits) >> freeBits)
-		} else {
-			shift := 32 - size
-			low = (value << shift)
 		}
-		binary.BigEndian.PutUint32(buf, low)
+		binary.BigEndian.PutUint32(buf, value<<(32-spill))
 		p.packed = append(p.packed[:], buf[:]...)
 	}
 	p.bitLength += size
 }
 
+// Pack returns the packed bits padded with zeros to a multiple of 32 bits.
 func (p *Pack) Pack() []byte {
 	return p.packed
 }
 
+// Bytes returns the packed bits padded with zeros to the next byte boundary only.
+func (p *Pack) Bytes() []byte {
+	return p.packed[:(p.bitLength+7)/8]
+}
+
+// BitLen returns the number of bits pushed so far.
+func (p *Pack) BitLen() uint32 {
+	return p.bitLength
+}
+
 func (p *Pack) PackedString() string {
 	var b bytes.Buffer
 	for i, w := range p.packed {
@@ -79,14 +90,24 @@ func (p *Pack) String() string {
 	return buf.String()
 }
 
-func Enc(n, B uint32, packer *Pack) *Pack {
-	max := uint32(1) << uint32(B)
-	if n < max {
+// Enc pushes n with the variable-length encoding of section "5.4 Encoding", starting with B bits
+// for the value.
+func Enc(n uint64, B uint32, packer Pusher) Pusher {
+	if B >= 64 || n < uint64(1)<<B {
 		packer.Push(uint32(0), uint32(1))
-		packer.Push(uint32(n), uint32(B))
+		push64(packer, n, B)
 	} else {
 		packer.Push(uint32(1), uint32(1))
-		Enc(n-max, B+1, packer)
+		Enc(n-uint64(1)<<B, B+1, packer)
 	}
 	return packer
 }
+
+// push64 pushes the lowest size bits (at most 64) of value in chunks of at most 32 bits.
+func push64(packer Pusher, value uint64, size uint32) {
+	if size > 32 {
+		packer.Push(uint32(value>>32), size-32)
+		size = 32
+	}
+	packer.Push(uint32(value), size)
+}
@@ -1,7 +1,10 @@
 package bit
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"testing"
 )
 
 func ExamplePackSimple() {
@@ -42,3 +45,63 @@ func ExamplePackUneven() {
 func exercisePack(bp *Pack) (string, string) {
 	return bp.String(), bp.PackedString()
 }
+
+func TestPackAcrossWords(t *testing.T) {
+	bp := NewPack()
+	entries := []struct{ value, size uint32 }{{1, 30}, {21, 5}, {0, 28}, {3, 2}, {5, 3}}
+	for _, entry := range entries {
+		bp.Push(entry.value, entry.size)
+	}
+	bup := NewUnPack(bp.Pack())
+	for _, entry := range entries {
+		if value, err := bup.Pop(entry.size); err != nil || value != entry.value {
+			t.Errorf("Pop(%d) = %d, %v - expected %d", entry.size, value, err, entry.value)
+		}
+	}
+}
+
+func TestPackBytes(t *testing.T) {
+	bp := NewPack()
+	if len(bp.Bytes()) != 0 || bp.BitLen() != 0 {
+		t.Errorf("empty pack has %d bits in % x", bp.BitLen(), bp.Bytes())
+	}
+	bp.Push(uint32(6), uint32(3))
+	bp.Push(uint32(6), uint32(3))
+	bp.Push(uint32(6), uint32(3))
+	if data := bp.Bytes(); bp.BitLen() != 9 || len(data) != 2 || data[0] != 0xdb || data[1] != 0x00 {
+		t.Errorf("Bytes() of %d bits = % x - expected db 00", bp.BitLen(), data)
+	}
+	bup := NewUnPack(bp.Bytes())
+	for n := 0; n < 3; n++ {
+		if value, err := bup.Pop(uint32(3)); err != nil || value != 6 {
+			t.Errorf("Pop(3) from unaligned data = %d, %v - expected 6", value, err)
+		}
+	}
+	if _, err := bup.Pop(uint32(8)); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Pop(8) beyond the end returned %v - expected %v", err, ErrTruncated)
+	}
+}
+
+func TestEncDecRoundTrip(t *testing.T) {
+	values := []uint64{0, 3, 4, 1<<32 - 1, 1 << 32, 1<<63 - 5, 1 << 63, math.MaxUint64 - 4, math.MaxUint64}
+	for _, n := range values {
+		bp := NewPack()
+		Enc(n, uint32(2), bp)
+		if decoded, err := Dec(uint32(2), NewUnPack(bp.Bytes())); err != nil || decoded != n {
+			t.Errorf("Dec(Enc(%d)) = %d, %v", n, decoded, err)
+		}
+	}
+}
+
+func TestDecOutOfRange(t *testing.T) {
+	// the longest prefix followed by 64 bits that exceed the range covered by the prefix
+	bp := NewPack()
+	bp.Push(uint32(0xffffffff), uint32(32))
+	bp.Push(uint32(0x3fffffff), uint32(30))
+	bp.Push(uint32(0), uint32(1))
+	bp.Push(uint32(0xffffffff), uint32(32))
+	bp.Push(uint32(0xffffffff), uint32(32))
+	if _, err := Dec(uint32(2), NewUnPack(bp.Bytes())); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Dec beyond the range of uint64 returned %v - expected %v", err, ErrMalformed)
+	}
+}
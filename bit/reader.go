@@ -0,0 +1,74 @@
+package bit
+
+import (
+	"io"
+)
+
+// Reader pops bits directly from an io.Reader. Bytes are read one at a time, using ReadByte if the
+// io.Reader implements io.ByteReader (e.g. bufio.Reader), so the Reader never consumes more than
+// the bytes holding the bits popped so far.
+type Reader struct {
+	r       io.Reader
+	br      io.ByteReader
+	current byte
+	bits    uint32
+	count   int64
+	scratch [1]byte
+}
+
+func NewReader(r io.Reader) *Reader {
+	br, _ := r.(io.ByteReader)
+	return &Reader{r: r, br: br}
+}
+
+// Pop reads the next size bits (at most 32) and returns them as value. io.EOF is returned if the
+// input ends at a byte boundary before the first bit of value and ErrTruncated if it ends within
+// value. Other read errors are returned as they are.
+func (r *Reader) Pop(size uint32) (value uint32, err error) {
+	if size > 32 {
+		return 0, ErrMalformed
+	}
+	for read := uint32(0); read < size; {
+		if r.bits == 0 {
+			if err := r.next(); err != nil {
+				if err == io.EOF && read > 0 {
+					err = ErrTruncated
+				}
+				return 0, err
+			}
+		}
+		n := r.bits
+		if n > size-read {
+			n = size - read
+		}
+		value = value<<n | uint32(r.current>>(r.bits-n))&(1<<n-1)
+		r.bits -= n
+		read += n
+	}
+	return
+}
+
+// Align drops the bits remaining in the current byte, so that the next Pop starts at a byte boundary.
+func (r *Reader) Align() {
+	r.bits = 0
+}
+
+// Count returns the number of bytes read so far.
+func (r *Reader) Count() int64 {
+	return r.count
+}
+
+func (r *Reader) next() (err error) {
+	if r.br != nil {
+		r.current, err = r.br.ReadByte()
+	} else {
+		_, err = io.ReadFull(r.r, r.scratch[:])
+		r.current = r.scratch[0]
+	}
+	if err != nil {
+		return err
+	}
+	r.count++
+	r.bits = 8
+	return nil
+}
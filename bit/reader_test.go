@@ -0,0 +1,51 @@
+package bit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestReaderMatchesUnPack(t *testing.T) {
+	data := []byte{0x44, 0x00, 0x00, 0x01, 0x80, 0x00, 0x00, 0x00, 0xa5}
+	sizes := []uint32{3, 1, 2, 25, 2, 32, 7}
+	for _, buffered := range []bool{false, true} {
+		var r *Reader
+		if buffered {
+			r = NewReader(bufio.NewReader(bytes.NewReader(data)))
+		} else {
+			r = NewReader(bytes.NewReader(data))
+		}
+		bup := NewUnPack(data)
+		for _, size := range sizes {
+			expected, _ := bup.Pop(size)
+			if value, err := r.Pop(size); err != nil || value != expected {
+				t.Errorf("Pop(%d) = %d, %v - expected %d", size, value, err, expected)
+			}
+		}
+		if _, err := r.Pop(uint32(1)); err != io.EOF {
+			t.Errorf("Pop at end of input = %v - expected %v", err, io.EOF)
+		}
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{0xff}))
+	if _, err := r.Pop(uint32(12)); err != ErrTruncated {
+		t.Errorf("Pop(12) = %v - expected %v", err, ErrTruncated)
+	}
+}
+
+func TestReaderDoesNotReadAhead(t *testing.T) {
+	br := bytes.NewReader([]byte{0xf0, 0x0f})
+	r := NewReader(br)
+	r.Pop(uint32(4))
+	if br.Len() != 1 || r.Count() != 1 {
+		t.Errorf("Reader consumed %d bytes, counted %d - expected 1", 2-br.Len(), r.Count())
+	}
+	r.Align()
+	if value, _ := r.Pop(uint32(8)); value != 0x0f {
+		t.Errorf("Pop(8) after Align() = %#x - expected 0x0f", value)
+	}
+}
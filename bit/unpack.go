@@ -1,37 +1,145 @@
 package bit
 
 import (
-	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
 )
 
+var (
+	// ErrTruncated is returned when the packed data ends before the requested bits could be read.
+	ErrTruncated = errors.New("bit: truncated input")
+	// ErrMalformed is returned when the packed data does not encode a valid value.
+	ErrMalformed = errors.New("bit: malformed input")
+)
+
+// Popper is implemented by UnPack and Reader, which both consume bits of an encoding.
+type Popper interface {
+	Pop(size uint32) (uint32, error)
+}
+
+// OffsetError records the bit offset within the packed data at which decoding failed.
+type OffsetError struct {
+	Offset uint32
+	Err    error
+}
+
+func (e *OffsetError) Error() string {
+	return fmt.Sprintf("%v at bit %d", e.Err, e.Offset)
+}
+
+func (e *OffsetError) Unwrap() error {
+	return e.Err
+}
+
+// UnPack pops bits from packed data in memory. Like bufio.Scanner, it stops at the first error:
+// the error is kept as an OffsetError, returned by every following Pop and available from Err.
 type UnPack struct {
 	index  uint32
 	packed []byte
+	err    error
 }
 
 func NewUnPack(packed []byte) *UnPack {
 	return &UnPack{packed: packed}
 }
 
-func (bup *UnPack) Pop(size uint32) (value uint32) {
-	byteIndex := (bup.index / 8) / 4
-	offset := bup.index % 32
-	value = binary.BigEndian.Uint32(bup.packed[byteIndex : byteIndex+4])
-	shift := 32 - size
-	value = uint32(value<<(offset)) >> shift
-	if offset > shift {
-		remain := offset - shift
-		low := binary.BigEndian.Uint32(bup.packed[byteIndex+4:byteIndex+8]) >> (32 - remain)
-		value = value | low
+// Pop reads the next size bits (at most 32) and returns them as value. ErrTruncated is returned
+// if the packed data does not hold size more bits. The packed data need not be aligned to words.
+func (bup *UnPack) Pop(size uint32) (value uint32, err error) {
+	if bup.err != nil {
+		return 0, bup.err
+	}
+	if size > 32 {
+		return 0, bup.fail(ErrMalformed)
+	}
+	if uint64(size) > uint64(bup.Remaining()) {
+		return 0, bup.fail(ErrTruncated)
+	}
+	for size > 0 {
+		offset := bup.index % 8
+		n := 8 - offset
+		if n > size {
+			n = size
+		}
+		bits := uint32(bup.packed[bup.index/8]) >> (8 - offset - n) & (1<<n - 1)
+		value = value<<n | bits
+		size -= n
+		bup.index += n
 	}
-	bup.index += size
 	return
 }
 
-func Dec(B uint32, unpacker *UnPack) uint32 {
-	max := uint32(1) << uint32(B)
-	if unpacker.Pop(uint32(1)) == 0 {
-		return unpacker.Pop(B)
+// Remaining returns the number of bits that have not been popped yet, including the padding.
+func (bup *UnPack) Remaining() int {
+	return 8*len(bup.packed) - int(bup.index)
+}
+
+// Err returns the first error that occurred while popping, or nil.
+func (bup *UnPack) Err() error {
+	return bup.err
+}
+
+// fail keeps err at the current offset as the sticky error, unless there already is one, and returns
+// the sticky error.
+func (bup *UnPack) fail(err error) error {
+	if bup.err == nil {
+		bup.err = &OffsetError{Offset: bup.index, Err: err}
+	}
+	return bup.err
+}
+
+// failer is implemented by poppers that keep a sticky error, such as UnPack.
+type failer interface {
+	fail(err error) error
+}
+
+// fail reports err to unpacker if it keeps a sticky error and returns the error to be passed on.
+func fail(unpacker Popper, err error) error {
+	if f, ok := unpacker.(failer); ok {
+		return f.fail(err)
+	}
+	return err
+}
+
+// Dec pops a value encoded by Enc starting with B bits. Prefixes longer than any uint64 needs and
+// values beyond the range of uint64 are rejected with ErrMalformed.
+func Dec(B uint32, unpacker Popper) (uint64, error) {
+	if B > 64 {
+		// Enc never needs more than 64 bits for a uint64
+		return 0, fail(unpacker, ErrMalformed)
+	}
+	prefix, err := unpacker.Pop(uint32(1))
+	if err != nil {
+		return 0, err
+	}
+	if prefix == 0 {
+		return pop64(unpacker, B)
+	}
+	n, err := Dec(B+1, unpacker)
+	if err != nil {
+		return 0, err
+	}
+	max := uint64(1) << B
+	if n > math.MaxUint64-max {
+		return 0, fail(unpacker, ErrMalformed)
+	}
+	return max + n, nil
+}
+
+// pop64 pops size bits (at most 64) in chunks of at most 32 bits.
+func pop64(unpacker Popper, size uint32) (uint64, error) {
+	high := uint32(0)
+	if size > 32 {
+		var err error
+		if high, err = unpacker.Pop(size - 32); err != nil {
+			return 0, err
+		}
+		size = 32
+	}
+	low, err := unpacker.Pop(size)
+	if err != nil {
+		return 0, err
 	}
-	return max + Dec(B+1, unpacker)
+	return uint64(high)<<32 | uint64(low), nil
 }
@@ -1,15 +1,24 @@
 package bit
 
-import "fmt"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
 
 func ExamplePopSimple() {
 	bup := NewUnPack([]byte{0x44, 0x00, 0x00, 0x01, 0x80, 0x00, 0x00, 0x00})
+	pop := func(size uint32) uint32 {
+		value, _ := bup.Pop(size)
+		return value
+	}
 	fmt.Printf("Base = 010001\n")
-	fmt.Printf("Pop(3) = %d\n", bup.Pop(uint32(3)))
-	fmt.Printf("Pop(1) = %d\n", bup.Pop(uint32(1)))
-	fmt.Printf("Pop(2) = %d\n", bup.Pop(uint32(2)))
-	fmt.Printf("Pop(25) = %d\n", bup.Pop(uint32(25)))
-	fmt.Printf("Pop(2) = %d\n", bup.Pop(uint32(2)))
+	fmt.Printf("Pop(3) = %d\n", pop(uint32(3)))
+	fmt.Printf("Pop(1) = %d\n", pop(uint32(1)))
+	fmt.Printf("Pop(2) = %d\n", pop(uint32(2)))
+	fmt.Printf("Pop(25) = %d\n", pop(uint32(25)))
+	fmt.Printf("Pop(2) = %d\n", pop(uint32(2)))
 	// Output:
 	// Base = 010001
 	// Pop(3) = 2
@@ -18,3 +27,91 @@ func ExamplePopSimple() {
 	// Pop(25) = 0
 	// Pop(2) = 3
 }
+
+func TestPopSecondWord(t *testing.T) {
+	bup := NewUnPack([]byte{0x00, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x01, 0x80, 0x00, 0x00, 0x00})
+	steps := []struct{ size, value uint32 }{{32, 0}, {3, 5}, {28, 0}, {2, 3}}
+	for _, step := range steps {
+		value, err := bup.Pop(step.size)
+		if err != nil || value != step.value {
+			t.Errorf("Pop(%d) = %d, %v - expected %d", step.size, value, err, step.value)
+		}
+	}
+}
+
+func TestPopTruncated(t *testing.T) {
+	bup := NewUnPack([]byte{0xff, 0xff, 0xff, 0xff})
+	if _, err := bup.Pop(uint32(30)); err != nil {
+		t.Fatalf("Pop(30) failed unexpectedly: %v", err)
+	}
+	if _, err := bup.Pop(uint32(3)); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Pop(3) beyond the end returned %v - expected %v", err, ErrTruncated)
+	}
+	if _, err := NewUnPack(nil).Pop(uint32(1)); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Pop(1) on empty input returned %v - expected %v", err, ErrTruncated)
+	}
+}
+
+func TestDecTruncated(t *testing.T) {
+	if _, err := Dec(uint32(2), NewUnPack([]byte{0xff, 0xff, 0xff, 0xf0})); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Dec on endless prefix returned %v - expected %v", err, ErrTruncated)
+	}
+}
+
+func TestDecMalformed(t *testing.T) {
+	data := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if _, err := Dec(uint32(2), NewUnPack(data)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Dec on overlong prefix returned %v - expected %v", err, ErrMalformed)
+	}
+}
+
+func TestUnPackStickyError(t *testing.T) {
+	bup := NewUnPack([]byte{0xff, 0xc0})
+	if _, err := bup.Pop(uint32(10)); err != nil || bup.Remaining() != 6 {
+		t.Fatalf("Pop(10) = %v, Remaining() = %d - expected 6 bits left", err, bup.Remaining())
+	}
+	_, err := bup.Pop(uint32(7))
+	var offsetErr *OffsetError
+	if !errors.As(err, &offsetErr) || offsetErr.Offset != 10 || offsetErr.Err != ErrTruncated {
+		t.Fatalf("Pop(7) beyond the end returned %v - expected %v at bit 10", err, ErrTruncated)
+	}
+	if _, again := bup.Pop(uint32(1)); again != err || bup.Err() != err {
+		t.Errorf("Pop(1) after error returned %v, Err() = %v - expected sticky %v", again, bup.Err(), err)
+	}
+	if bup.Remaining() != 6 {
+		t.Errorf("Remaining() after error = %d - expected 6", bup.Remaining())
+	}
+}
+
+func TestDecMalformedOffset(t *testing.T) {
+	bup := NewUnPack([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	Dec(uint32(2), bup)
+	if err := bup.Err(); err == nil || err.Error() != "bit: malformed input at bit 63" {
+		t.Errorf("Err() after Dec on overlong prefix = %v - expected malformed input at bit 63", err)
+	}
+}
+
+func FuzzUnPack(f *testing.F) {
+	f.Add([]byte{0x44, 0x00, 0x00, 0x01, 0x80}, []byte{3, 1, 2, 25, 2})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xf0}, []byte{2})
+	f.Fuzz(func(t *testing.T, data, sizes []byte) {
+		bup := NewUnPack(data)
+		r := NewReader(bytes.NewReader(data))
+		for _, size := range sizes {
+			remaining := bup.Remaining()
+			value, err := bup.Pop(uint32(size))
+			if err != nil {
+				if bup.Err() != err || bup.Remaining() != remaining {
+					t.Fatalf("Pop(%d) = %v changed the state, Err() = %v", size, err, bup.Err())
+				}
+				return
+			}
+			if bup.Remaining() != remaining-int(size) {
+				t.Fatalf("Pop(%d) left %d of %d bits", size, bup.Remaining(), remaining)
+			}
+			if streamed, err := r.Pop(uint32(size)); err != nil || streamed != value {
+				t.Fatalf("Pop(%d) = %d - Reader popped %d, %v", size, value, streamed, err)
+			}
+		}
+	})
+}
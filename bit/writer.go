@@ -0,0 +1,70 @@
+package bit
+
+import (
+	"io"
+)
+
+// Writer pushes bits directly to an io.Writer. Completed bytes are written one at a time, using
+// WriteByte if the io.Writer implements io.ByteWriter (e.g. bufio.Writer), so no buffer is
+// allocated for the encoding. The first write error is kept and returned by Flush.
+type Writer struct {
+	w       io.Writer
+	bw      io.ByteWriter
+	current byte
+	bits    uint32
+	count   int64
+	err     error
+	scratch [1]byte
+}
+
+func NewWriter(w io.Writer) *Writer {
+	bw, _ := w.(io.ByteWriter)
+	return &Writer{w: w, bw: bw}
+}
+
+// Push appends the lowest size bits (at most 32) of value.
+func (w *Writer) Push(value, size uint32) {
+	for size > 0 {
+		n := 8 - w.bits
+		if n > size {
+			n = size
+		}
+		size -= n
+		w.current |= byte(value>>size&(1<<n-1)) << (8 - w.bits - n)
+		w.bits += n
+		if w.bits == 8 {
+			w.writeByte(w.current)
+			w.current, w.bits = 0, 0
+		}
+	}
+}
+
+// Flush pads the pushed bits with zeros to the next byte boundary, writes the last byte and
+// returns the first error that occurred while writing, if any.
+func (w *Writer) Flush() error {
+	if w.bits > 0 {
+		w.writeByte(w.current)
+		w.current, w.bits = 0, 0
+	}
+	return w.err
+}
+
+// Count returns the number of bytes written so far.
+func (w *Writer) Count() int64 {
+	return w.count
+}
+
+func (w *Writer) writeByte(b byte) {
+	if w.err != nil {
+		return
+	}
+	if w.bw != nil {
+		w.err = w.bw.WriteByte(b)
+	} else {
+		w.scratch[0] = b
+		_, w.err = w.w.Write(w.scratch[:])
+	}
+	if w.err == nil {
+		w.count++
+	}
+}
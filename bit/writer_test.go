@@ -0,0 +1,49 @@
+package bit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func ExampleWriter() {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Push(uint32(2), uint32(3))
+	w.Push(uint32(1), uint32(3))
+	w.Push(uint32(3), uint32(4))
+	w.Flush()
+	fmt.Printf("% x\n", buf.Bytes())
+	// Output:
+	// 44 c0
+}
+
+func TestWriterMatchesPack(t *testing.T) {
+	steps := []struct{ value, size uint32 }{{1, 1}, {0xdeadbeef, 32}, {5, 3}, {0, 7}, {0x1ffff, 17}, {3, 2}}
+	for _, buffered := range []bool{false, true} {
+		var buf bytes.Buffer
+		var w *Writer
+		var bw *bufio.Writer
+		if buffered {
+			bw = bufio.NewWriter(&buf)
+			w = NewWriter(bw)
+		} else {
+			w = NewWriter(&buf)
+		}
+		p := NewPack()
+		for _, step := range steps {
+			w.Push(step.value, step.size)
+			p.Push(step.value, step.size)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("Flush() = %v", err)
+		}
+		if bw != nil {
+			bw.Flush()
+		}
+		if !bytes.Equal(buf.Bytes(), p.Bytes()) || w.Count() != int64(len(p.Bytes())) {
+			t.Errorf("Writer wrote % x (%d bytes) - expected % x", buf.Bytes(), w.Count(), p.Bytes())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package itc
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/fgrid/itc/bit"
+)
+
+// BinaryEnvelope makes MarshalBinary wrap the encoded stamp in an envelope of a magic byte, the format
+// version and the length of the encoding in bits. UnmarshalBinary accepts stamps with and without
+// envelope regardless, so all replicas can be upgraded to decode envelopes before any of them starts
+// to create them. BinaryEnvelope is meant to be set once during initialization.
+var BinaryEnvelope = false
+
+var (
+	// ErrUnsupportedVersion is returned for envelopes of an unknown format version.
+	ErrUnsupportedVersion = errors.New("itc: unsupported binary format version")
+	// ErrEnvelopeLength is returned for envelopes whose length does not match the encoded stamp, e.g.
+	// because they were cut off or extended by a framing error.
+	ErrEnvelopeLength = errors.New("itc: envelope length does not match the encoded stamp")
+)
+
+const (
+	// envelopeMagic starts an envelope. Plain encodings never start with it, since its bits 01000
+	// encode the ID (0, 0), which is not normalized and thus rejected.
+	envelopeMagic = 0x45
+	// envelopeVersion is the current version of the bit encoding.
+	envelopeVersion = 1
+)
+
+// envelope returns the packed bits of bp wrapped in an envelope.
+func envelope(bp *bit.Pack) []byte {
+	header := make([]byte, 2+binary.MaxVarintLen32)
+	header[0], header[1] = envelopeMagic, envelopeVersion
+	n := binary.PutUvarint(header[2:], uint64(bp.BitLen()))
+	return append(header[:2+n], bp.Bytes()...)
+}
+
+// unwrapEnvelope returns the encoded stamp within data and its length in bits, which is data itself
+// and -1 if it has no envelope. The encoded stamp has to fill the envelope up to the padding of its
+// last byte.
+func unwrapEnvelope(data []byte) ([]byte, int, error) {
+	if len(data) == 0 || data[0] != envelopeMagic {
+		return data, -1, nil
+	}
+	if len(data) < 2 {
+		return nil, 0, bit.ErrTruncated
+	}
+	if data[1] != envelopeVersion {
+		return nil, 0, ErrUnsupportedVersion
+	}
+	bitLength, n := binary.Uvarint(data[2:])
+	if n == 0 {
+		return nil, 0, bit.ErrTruncated
+	}
+	if n < 0 {
+		return nil, 0, bit.ErrMalformed
+	}
+	payload := data[2+n:]
+	if bitLength > uint64(len(payload))*8 {
+		return nil, 0, bit.ErrTruncated
+	}
+	if uint64(len(payload)) != (bitLength+7)/8 {
+		return nil, 0, ErrEnvelopeLength
+	}
+	return payload, int(bitLength), nil
+}
@@ -0,0 +1,60 @@
+package itc
+
+import (
+	"fmt"
+	"github.com/fgrid/itc/bit"
+	"testing"
+)
+
+func ExampleBinaryEnvelope() {
+	seed := NewStamp()
+	plain, _ := seed.MarshalBinary()
+	BinaryEnvelope = true
+	enveloped, _ := seed.MarshalBinary()
+	BinaryEnvelope = false
+	fmt.Printf("% x\n% x\n", plain, enveloped)
+	// Output:
+	// 30
+	// 45 01 07 30
+}
+
+func TestUnmarshalBinaryEnvelope(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	a.Event()
+	b.Event()
+	a.Join(b)
+	defer func() { BinaryEnvelope = false }()
+	for _, enveloped := range []bool{false, true} {
+		BinaryEnvelope = enveloped
+		for _, s := range []*Stamp{a, b, NewStamp()} {
+			data, _ := s.MarshalBinary()
+			decoded := NewStamp()
+			if err := decoded.UnmarshalBinary(data); err != nil || !decoded.Equal(s) {
+				t.Errorf("UnmarshalBinary(% x) = %s, %v - expected %s", data, decoded, err, s)
+			}
+		}
+	}
+}
+
+func TestUnmarshalBinaryInvalidEnvelope(t *testing.T) {
+	invalid := []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte{0x45}, bit.ErrTruncated},
+		{[]byte{0x45, 0x02, 0x07, 0x30, 0x00, 0x00, 0x00}, ErrUnsupportedVersion},
+		{[]byte{0x45, 0x01}, bit.ErrTruncated},
+		{[]byte{0x45, 0x01, 0x21, 0x30, 0x00, 0x00, 0x00}, bit.ErrTruncated},
+		{[]byte{0x45, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, bit.ErrMalformed},
+		{[]byte{0x45, 0x01, 0x01, 0x30}, ErrEnvelopeLength},
+		{[]byte{0x45, 0x01, 0x07, 0x30, 0xff, 0xff}, ErrEnvelopeLength},
+		{[]byte{0x45, 0x01, 0x08, 0x30}, ErrEnvelopeLength},
+	}
+	for _, test := range invalid {
+		s := NewStamp()
+		if err := s.UnmarshalBinary(test.data); err != test.err {
+			t.Errorf("UnmarshalBinary(% x) returned %v - expected %v", test.data, err, test.err)
+		}
+	}
+}
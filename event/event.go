@@ -1,36 +1,47 @@
 package event
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fgrid/itc/bit"
+	"math"
+)
+
+var (
+	// ErrMalformedEvent is returned for events that are neither a leaf nor a node with two children.
+	ErrMalformedEvent = errors.New("event: malformed event")
+	// ErrNotNormalized is returned for events that are not in normal form (see section "5.2 Normal form").
+	ErrNotNormalized = errors.New("event: event not normalized")
+	// ErrOverflow is returned when an event counter would exceed the range of uint64.
+	ErrOverflow = errors.New("event: counter overflow")
 )
 
 type Event struct {
-	Value       uint32
+	Value       uint64
 	Left, Right *Event
 	IsLeaf      bool
 }
 
 const (
-	zero  = uint32(0)
-	one   = uint32(1)
-	two   = uint32(2)
-	three = uint32(3)
+	zero  = 0
+	one   = 1
+	two   = 2
+	three = 3
 )
 
 func New() *Event {
 	return NewLeaf(zero)
 }
 
-func NewLeaf(value uint32) *Event {
+func NewLeaf(value uint64) *Event {
 	return &Event{Value: value, IsLeaf: true}
 }
 
-func NewEmptyNode(value uint32) *Event {
+func NewEmptyNode(value uint64) *Event {
 	return &Event{Value: value, IsLeaf: false, Left: New(), Right: New()}
 }
 
-func NewNode(value, left, right uint32) *Event {
+func NewNode(value, left, right uint64) *Event {
 	return &Event{Value: value, IsLeaf: false, Left: NewLeaf(left), Right: NewLeaf(right)}
 }
 
@@ -48,50 +59,107 @@ func (e *Event) Clone() *Event {
 }
 
 func (e *Event) Equals(o *Event) bool {
-	return (e == nil && o == nil) ||
+	return e == o ||
 		((e.IsLeaf == o.IsLeaf) &&
 			(e.Value == o.Value) &&
 			e.Left.Equals(o.Left) &&
 			e.Right.Equals(o.Right))
 }
 
+// Norm returns the normalized form of the event e as defined in section "5.2 Normal form".
+// The event e itself is left unchanged and returned if it is normalized already.
 func (e *Event) Norm() *Event {
 	if e.IsLeaf {
 		return e
 	}
+	return e.with(e.Left.Norm(), e.Right.Norm())
+}
+
+// Node returns the normalized event (value, left, right) for the normalized subtrees left and right.
+// Only the new root and the sunk roots of the subtrees are allocated, the rest of the trees is shared.
+func Node(value uint64, left, right *Event) *Event {
+	if left.IsLeaf && right.IsLeaf && left.Value == right.Value {
+		return NewLeaf(value + left.Value)
+	}
+	m := Min(left.Value, right.Value)
+	return &Event{Value: value + m, Left: left.sink(m), Right: right.sink(m)}
+}
+
+// with returns the normalized node e with its subtrees replaced by the normalized left and right,
+// which is e itself if nothing has to change.
+func (e *Event) with(left, right *Event) *Event {
+	if left == e.Left && right == e.Right && Min(left.Value, right.Value) == 0 &&
+		!(left.IsLeaf && right.IsLeaf && left.Value == right.Value) {
+		return e
+	}
+	return Node(e.Value, left, right)
+}
+
+// Validate checks that the event e is well formed, i.e. leaves have no children and nodes have two
+// children, that it is in normal form as defined in section "5.2 Normal form" and that no counter
+// exceeds the range of uint64 (ErrOverflow).
+func (e *Event) Validate() error {
+	return e.validate(zero)
+}
+
+// validate checks the event e below ancestors with the accumulated value base.
+func (e *Event) validate(base uint64) error {
+	if e.Value > math.MaxUint64-base {
+		return ErrOverflow
+	}
+	if e.IsLeaf {
+		if e.Left != nil || e.Right != nil {
+			return ErrMalformedEvent
+		}
+		return nil
+	}
+	if e.Left == nil || e.Right == nil {
+		return ErrMalformedEvent
+	}
+	if err := e.Left.validate(base + e.Value); err != nil {
+		return err
+	}
+	if err := e.Right.validate(base + e.Value); err != nil {
+		return err
+	}
+	// normalized subtrees have their minimum as value, one of which has to be sunk to 0
+	if e.Left.Value != 0 && e.Right.Value != 0 {
+		return ErrNotNormalized
+	}
 	if e.Left.IsLeaf && e.Right.IsLeaf && e.Left.Value == e.Right.Value {
-		return NewLeaf(e.Value + e.Left.Value)
+		return ErrNotNormalized
 	}
-	m := Min(e.Left.Min(), e.Right.Min())
-	e.Left = e.Left.Norm().sink(m)
-	e.Right = e.Right.Norm().sink(m)
-	return e.lift(m)
+	return nil
 }
 
-func (e *Event) lift(value uint32) *Event {
-	result := e.Clone()
-	result.Value += value
-	return result
+// lift returns e raised by value, sharing the subtrees of e.
+func (e *Event) lift(value uint64) *Event {
+	if value == 0 {
+		return e
+	}
+	return &Event{Value: e.Value + value, Left: e.Left, Right: e.Right, IsLeaf: e.IsLeaf}
 }
 
-func (e *Event) Max() uint32 {
+func (e *Event) Max() uint64 {
 	if e.IsLeaf {
 		return e.Value
 	}
 	return e.Value + Max(e.Left.Max(), e.Right.Max())
 }
 
-func (e *Event) Min() uint32 {
+func (e *Event) Min() uint64 {
 	if e.IsLeaf {
 		return e.Value
 	}
 	return e.Value + Min(e.Left.Min(), e.Right.Min())
 }
 
-func (e *Event) sink(value uint32) *Event {
-	result := e.Clone()
-	result.Value -= value
-	return result
+// sink returns e lowered by value, sharing the subtrees of e.
+func (e *Event) sink(value uint64) *Event {
+	if value == 0 {
+		return e
+	}
+	return &Event{Value: e.Value - value, Left: e.Left, Right: e.Right, IsLeaf: e.IsLeaf}
 }
 
 func (e *Event) String() string {
@@ -102,57 +170,196 @@ func (e *Event) String() string {
 }
 
 // ----------
+
+// Join returns the normalized event of the events e1 and e2. Normalized subtrees of e1 and e2 that
+// dominate the other event are shared by the result.
 func Join(e1, e2 *Event) *Event {
-	if e1.IsLeaf && e2.IsLeaf {
-		return NewLeaf(Max(e1.Value, e2.Value))
+	e, which := join(e1, zero, e2, zero)
+	return pick(e, which, e1, zero, e2, zero)
+}
+
+// results of join besides a newly joined event
+const (
+	first = 1 << iota
+	second
+	both = first | second
+)
+
+// join computes the normalized event of e1 raised by d1 and e2 raised by d2, carrying the offsets
+// down the recursion instead of lifting copies of the subtrees. If one of the raised events dominates
+// the other one, only first, second or both (if they are equal) is returned, so that the caller can
+// share it.
+func join(e1 *Event, d1 uint64, e2 *Event, d2 uint64) (*Event, int) {
+	v1, v2 := d1+e1.Value, d2+e2.Value
+	if e1.IsLeaf && e2.IsLeaf && v1 == v2 {
+		return nil, both
 	}
-	if e1.IsLeaf {
-		return Join(NewEmptyNode(e1.Value), e2)
+	// an event is nowhere lower than its value
+	if e1.IsLeaf && v1 <= v2 {
+		return nil, second
 	}
-	if e2.IsLeaf {
-		return Join(e1, NewEmptyNode(e2.Value))
+	if e2.IsLeaf && v2 <= v1 {
+		return nil, first
+	}
+	m := Min(v1, v2)
+	l1, r1 := e1.children()
+	l2, r2 := e2.children()
+	left, lw := join(l1, v1-m, l2, v2-m)
+	right, rw := join(r1, v1-m, r2, v2-m)
+	if lw&rw != 0 {
+		return nil, lw & rw
+	}
+	left = pick(left, lw, l1, v1-m, l2, v2-m)
+	right = pick(right, rw, r1, v1-m, r2, v2-m)
+	return Node(m, left, right), 0
+}
+
+// pick returns the normalized event described by a result of join.
+func pick(e *Event, which int, e1 *Event, d1 uint64, e2 *Event, d2 uint64) *Event {
+	switch {
+	case which&first != 0:
+		return e1.Norm().lift(d1)
+	case which&second != 0:
+		return e2.Norm().lift(d2)
+	}
+	return e
+}
+
+// Meet returns the normalized event of the greatest lower bound (the pointwise minimum) of the
+// events e1 and e2, i.e. the events seen by both. Like with Join, dominated subtrees are shared.
+func Meet(e1, e2 *Event) *Event {
+	e, which := meet(e1, zero, e2, zero)
+	return pick(e, which, e1, zero, e2, zero)
+}
+
+// meet computes the normalized minimum of e1 raised by d1 and e2 raised by d2 like join computes
+// the maximum.
+func meet(e1 *Event, d1 uint64, e2 *Event, d2 uint64) (*Event, int) {
+	v1, v2 := d1+e1.Value, d2+e2.Value
+	if e1.IsLeaf && e2.IsLeaf && v1 == v2 {
+		return nil, both
+	}
+	// an event is nowhere lower than its value
+	if e1.IsLeaf && v1 <= v2 {
+		return nil, first
+	}
+	if e2.IsLeaf && v2 <= v1 {
+		return nil, second
+	}
+	m := Min(v1, v2)
+	l1, r1 := e1.children()
+	l2, r2 := e2.children()
+	left, lw := meet(l1, v1-m, l2, v2-m)
+	right, rw := meet(r1, v1-m, r2, v2-m)
+	if lw&rw != 0 {
+		return nil, lw & rw
+	}
+	left = pick(left, lw, l1, v1-m, l2, v2-m)
+	right = pick(right, rw, r1, v1-m, r2, v2-m)
+	return Node(m, left, right), 0
+}
+
+// Diff returns the delta of the normalized events from and to: the normalized event that equals to
+// wherever to is greater than from and is 0 everywhere else. Regions of to dominated by from collapse
+// into leaves, so the delta is small if to differs from from in few places only. The delta is an event
+// itself and can be encoded with Pack.
+func Diff(from, to *Event) *Event {
+	return diff(from, zero, to, zero)
+}
+
+// diff computes the delta of from raised by df and to raised by dt. The minimum of a normalized event
+// is its value, so to is either dominated by or entirely above a leaf of from.
+func diff(from *Event, df uint64, to *Event, dt uint64) *Event {
+	vf, vt := df+from.Value, dt+to.Value
+	if to.IsLeaf && vt <= vf {
+		return New()
 	}
-	if e1.Value > e2.Value {
-		return Join(e2, e1)
+	if from.IsLeaf && vf < vt {
+		return to.lift(dt)
 	}
-	e := NewEmptyNode(e1.Value)
-	e.Left = Join(e1.Left, e2.Left.lift(e2.Value-e1.Value))
-	e.Right = Join(e1.Right, e2.Right.lift(e2.Value-e1.Value))
-	return e.Norm()
+	lf, rf := from.children()
+	lt, rt := to.children()
+	return Node(zero, diff(lf, vf, lt, vt), diff(rf, vf, rt, vt))
 }
 
+// ApplyDelta returns the normalized event e updated with a delta created by Diff. If e is at least the
+// event the delta was created from, the result equals the join of e with the event the delta leads to.
+func ApplyDelta(e, delta *Event) *Event {
+	return Join(e, delta)
+}
+
+// LEQ returns 'true' if the normalized event e1 is less or equal to the normalized event e2.
 func LEQ(e1, e2 *Event) bool {
+	return leq(e1, zero, e2, zero)
+}
+
+// leq compares e1 raised by d1 with e2 raised by d2, carrying the offsets down the recursion instead
+// of lifting copies of the subtrees.
+func leq(e1 *Event, d1 uint64, e2 *Event, d2 uint64) bool {
+	v1, v2 := d1+e1.Value, d2+e2.Value
+	if v1 > v2 {
+		return false
+	}
 	if e1.IsLeaf {
-		return e1.Value <= e2.Value
+		return true
 	}
 	if e2.IsLeaf {
-		return (e1.Value <= e2.Value) &&
-			LEQ(e1.Left.lift(e1.Value), e2) &&
-			LEQ(e1.Right.lift(e1.Value), e2)
+		return leq(e1.Left, v1, e2, d2) && leq(e1.Right, v1, e2, d2)
 	}
-	return (e1.Value <= e2.Value) &&
-		LEQ(e1.Left.lift(e1.Value), e2.Left.lift(e2.Value)) &&
-		LEQ(e1.Right.lift(e1.Value), e2.Right.lift(e2.Value))
+	return leq(e1.Left, v1, e2.Left, v2) && leq(e1.Right, v1, e2.Right, v2)
 }
 
-func Max(n1, n2 uint32) uint32 {
+// Compare relates the events e1 and e2 in a single walk over both trees. leq reports
+// whether e1 is less or equal to e2, geq whether e1 is greater or equal to e2.
+func Compare(e1, e2 *Event) (leq, geq bool) {
+	return compare(e1, zero, e2, zero)
+}
+
+var leaf0 = NewLeaf(zero)
+
+// compare walks e1 and e2 carrying the accumulated values b1 and b2 of their ancestors,
+// so that no lifted copies of the subtrees are needed.
+func compare(e1 *Event, b1 uint64, e2 *Event, b2 uint64) (leq, geq bool) {
+	v1, v2 := b1+e1.Value, b2+e2.Value
+	if e1.IsLeaf && e2.IsLeaf {
+		return v1 <= v2, v1 >= v2
+	}
+	l1, r1 := e1.children()
+	l2, r2 := e2.children()
+	leq, geq = compare(l1, v1, l2, v2)
+	if !leq && !geq {
+		return
+	}
+	leqR, geqR := compare(r1, v1, r2, v2)
+	return leq && leqR, geq && geqR
+}
+
+// children returns the subtrees of e, a leaf being treated as a node with two zero leaves.
+func (e *Event) children() (left, right *Event) {
+	if e.IsLeaf {
+		return leaf0, leaf0
+	}
+	return e.Left, e.Right
+}
+
+func Max(n1, n2 uint64) uint64 {
 	if n1 > n2 {
 		return n1
 	}
 	return n2
 }
 
-func Min(n1, n2 uint32) uint32 {
+func Min(n1, n2 uint64) uint64 {
 	if n1 < n2 {
 		return n1
 	}
 	return n2
 }
 
-func (e Event) Pack(bp *bit.Pack) {
+func (e Event) Pack(bp bit.Pusher) {
 	if e.IsLeaf {
 		bp.Push(one, one)
-		bit.Enc(uint32(e.Value), two, bp)
+		bit.Enc(e.Value, two, bp)
 		return
 	}
 
@@ -196,36 +403,68 @@ func (e Event) Pack(bp *bit.Pack) {
 	return
 }
 
-func UnPack(bup *bit.UnPack) *Event {
-	if bup.Pop(one) == 1 {
-		return NewLeaf(bit.Dec(two, bup))
+func UnPack(bup bit.Popper) (*Event, error) {
+	kind, err := bup.Pop(one)
+	if err != nil {
+		return nil, err
+	}
+	if kind == 1 {
+		value, err := bit.Dec(two, bup)
+		if err != nil {
+			return nil, err
+		}
+		return NewLeaf(value), nil
 	}
 	e := NewNode(zero, zero, zero)
-	switch bup.Pop(two) {
+	if kind, err = bup.Pop(two); err != nil {
+		return nil, err
+	}
+	switch kind {
 	case 0:
-		e.Right = UnPack(bup)
+		e.Right, err = UnPack(bup)
 	case 1:
-		e.Left = UnPack(bup)
+		e.Left, err = UnPack(bup)
 	case 2:
-		e.Left = UnPack(bup)
-		e.Right = UnPack(bup)
+		if e.Left, err = UnPack(bup); err == nil {
+			e.Right, err = UnPack(bup)
+		}
 	case 3:
-		if bup.Pop(one) == 0 {
-			if bup.Pop(one) == 0 {
-				bup.Pop(one)
-				e.Value = bit.Dec(two, bup)
-				e.Right = UnPack(bup)
-			} else {
-				bup.Pop(one)
-				e.Value = bit.Dec(two, bup)
-				e.Left = UnPack(bup)
-			}
-		} else {
-			bup.Pop(one)
-			e.Value = bit.Dec(two, bup)
-			e.Left = UnPack(bup)
-			e.Right = UnPack(bup)
+		err = unPackValueNode(e, bup)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// unPackValueNode decodes the children and the non-zero value of node e.
+func unPackValueNode(e *Event, bup bit.Popper) error {
+	both, err := bup.Pop(one)
+	if err != nil {
+		return err
+	}
+	onlyLeft := uint32(0)
+	if both == 0 {
+		if onlyLeft, err = bup.Pop(one); err != nil {
+			return err
 		}
 	}
-	return e
+	// skip the leaf marker of the node value
+	if _, err = bup.Pop(one); err != nil {
+		return err
+	}
+	if e.Value, err = bit.Dec(two, bup); err != nil {
+		return err
+	}
+	switch {
+	case both == 1:
+		if e.Left, err = UnPack(bup); err == nil {
+			e.Right, err = UnPack(bup)
+		}
+	case onlyLeft == 1:
+		e.Left, err = UnPack(bup)
+	default:
+		e.Right, err = UnPack(bup)
+	}
+	return err
 }
@@ -1,8 +1,11 @@
 package event
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fgrid/itc/bit"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -42,7 +45,7 @@ func TestEventNodeStringer(t *testing.T) {
 }
 
 func ExampleLiftLeafEvent() {
-	event := NewLeaf(uint32(4))
+	event := NewLeaf(uint64(4))
 	sourceString := event.String()
 	fmt.Printf("lift(%s, 3) = %s", sourceString, event.lift(three))
 	// Output:
@@ -58,7 +61,7 @@ func ExampleLiftNodeEvent() {
 }
 
 func ExampleSinkLeafEvent() {
-	event := NewLeaf(uint32(4))
+	event := NewLeaf(uint64(4))
 	sourceString := event.String()
 	fmt.Printf("sink(%s, 3) = %s", sourceString, event.sink(three))
 	// Output:
@@ -66,7 +69,7 @@ func ExampleSinkLeafEvent() {
 }
 
 func ExampleSinkNodeEvent() {
-	event := NewNode(uint32(4), two, three)
+	event := NewNode(uint64(4), two, three)
 	sourceString := event.String()
 	fmt.Printf("sink(%s, 3) = %s", sourceString, event.sink(three))
 	// Output:
@@ -74,7 +77,7 @@ func ExampleSinkNodeEvent() {
 }
 
 func ExampleNormLeafEvent() {
-	event := NewLeaf(uint32(4))
+	event := NewLeaf(uint64(4))
 	sourceString := event.String()
 	fmt.Printf("Norm(%s) = %s", sourceString, event.Norm())
 	// Output:
@@ -99,16 +102,27 @@ func ExampleNormNodeEventWithNodes() {
 	// Norm((2, (2, 1, 0), 3)) = (4, (0, 1, 0), 1)
 }
 
+func ExampleNormNodeEventWithEqualNodes() {
+	event := NewNode(zero, one, one)
+	event.Left = NewNode(zero, one, one)
+	sourceString := event.String()
+	fmt.Printf("Norm(%s) = %s\n", sourceString, event.Norm())
+	fmt.Printf("unchanged: %s", event)
+	// Output:
+	// Norm((0, (0, 1, 1), 1)) = 1
+	// unchanged: (0, (0, 1, 1), 1)
+}
+
 func ExampleMinOfLeafEvent() {
-	event := NewLeaf(uint32(4))
+	event := NewLeaf(uint64(4))
 	fmt.Printf("Min(%s) = %d", event, event.Min())
 	// Output:
 	// Min(4) = 4
 }
 
 func ExampleJoinLeafEvents() {
-	e1 := NewLeaf(uint32(7))
-	e2 := NewLeaf(uint32(9))
+	e1 := NewLeaf(uint64(7))
+	e2 := NewLeaf(uint64(9))
 	fmt.Printf("Join(%s, %s) = %s\n", e1, e2, Join(e1, e2))
 	// Output:
 	// Join(7, 9) = 9
@@ -116,12 +130,55 @@ func ExampleJoinLeafEvents() {
 
 func ExampleJoinNodeEvents() {
 	e1 := NewNode(one, two, three)
-	e2 := NewNode(uint32(4), uint32(5), uint32(6))
+	e2 := NewNode(uint64(4), uint64(5), uint64(6))
 	fmt.Printf("Join(%s, %s) = %s\n", e1, e2, Join(e1, e2))
 	// Output:
 	// Join((1, 2, 3), (4, 5, 6)) = (9, 0, 1)
 }
 
+func ExampleMeet() {
+	e1 := NewNode(one, zero, three)
+	e2 := Node(two, NewNode(zero, zero, one), New())
+	fmt.Printf("Meet(%s, %s) = %s\n", e1, e2, Meet(e1, e2))
+	// Output:
+	// Meet((1, 0, 3), (2, (0, 0, 1), 0)) = (1, 0, 1)
+}
+
+func ExampleDiff() {
+	from := Node(one, NewNode(zero, zero, two), NewNode(three, one, zero))
+	to := Node(one, NewNode(zero, zero, two), NewNode(three, two, zero))
+	delta := Diff(from, to)
+	fmt.Printf("Diff(%s, %s) = %s\n", from, to, delta)
+	fmt.Printf("ApplyDelta(%s, %s) = %s\n", from, delta, ApplyDelta(from, delta))
+	// Output:
+	// Diff((1, (0, 0, 2), (3, 1, 0)), (1, (0, 0, 2), (3, 2, 0))) = (0, 0, (0, 6, 0))
+	// ApplyDelta((1, (0, 0, 2), (3, 1, 0)), (0, 0, (0, 6, 0))) = (1, (0, 0, 2), (3, 2, 0))
+}
+
+func ExampleNode() {
+	fmt.Printf("Node(1, 2, 2) = %s\n", Node(one, NewLeaf(two), NewLeaf(two)))
+	fmt.Printf("Node(1, 2, (1, 0, 3)) = %s\n", Node(one, NewLeaf(two), NewNode(one, zero, three)))
+	// Output:
+	// Node(1, 2, 2) = 3
+	// Node(1, 2, (1, 0, 3)) = (2, 1, (0, 0, 3))
+}
+
+func TestJoinSharesDominatingEvent(t *testing.T) {
+	e1 := NewNode(one, zero, two)
+	e1.Left = NewNode(zero, three, zero)
+	e2 := NewNode(zero, one, one)
+	e2.Right = NewNode(zero, zero, one)
+	joined := Join(e1, e2)
+	if joined != e1 || Join(e2, e1) != e1 {
+		t.Errorf("Join(%s, %s) = %s - expected to share %s", e1, e2, joined, e1)
+	}
+	e2.Right = NewNode(zero, three, zero)
+	joined = Join(e1, e2)
+	if joined.Left != e1.Left {
+		t.Errorf("Join(%s, %s) = %s - expected to share %s", e1, e2, joined, e1.Left)
+	}
+}
+
 func ExampleEqualsNilEvents() {
 	var e1, e2 *Event
 	fmt.Printf("e1 = %q, e2 = %q => e1.Equals(e2) = %t", e1, e2, e1.Equals(e2))
@@ -173,11 +230,32 @@ func ExampleLeqLeftNode() {
 	// LEQ((2, 1, 1), (2, 1, 1)) = true
 }
 
+func ExampleCompare() {
+	e1 := NewNode(one, two, zero)
+	e2 := NewNode(two, zero, one)
+	e3 := NewLeaf(three)
+
+	leq, geq := Compare(e1, e2)
+	fmt.Printf("Compare(%s, %s) = %t, %t\n", e1, e2, leq, geq)
+	leq, geq = Compare(e1, e3)
+	fmt.Printf("Compare(%s, %s) = %t, %t\n", e1, e3, leq, geq)
+	leq, geq = Compare(e3, e1)
+	fmt.Printf("Compare(%s, %s) = %t, %t\n", e3, e1, leq, geq)
+	leq, geq = Compare(e3, NewNode(one, two, two))
+	fmt.Printf("Compare(%s, %s) = %t, %t\n", e3, NewNode(one, two, two), leq, geq)
+
+	// Output:
+	// Compare((1, 2, 0), (2, 0, 1)) = false, false
+	// Compare((1, 2, 0), 3) = true, false
+	// Compare(3, (1, 2, 0)) = false, true
+	// Compare(3, (1, 2, 2)) = true, true
+}
+
 func ExampleBitPack_EncodeEvent_Leaves() {
 	source0 := NewLeaf(one)
-	source1 := NewLeaf(uint32(4))
-	source2 := NewLeaf(uint32(8))
-	source3 := NewLeaf(uint32(13))
+	source1 := NewLeaf(uint64(4))
+	source2 := NewLeaf(uint64(8))
+	source3 := NewLeaf(uint64(13))
 	pack0 := bit.NewPack()
 	pack1 := bit.NewPack()
 	pack2 := bit.NewPack()
@@ -237,19 +315,19 @@ func ExampleBitUnPack_decodeEvent_Leaves() {
 	packer := bit.NewPack()
 	NewLeaf(zero).Pack(packer)
 	unpacker := bit.NewUnPack(packer.Pack())
-	event0 := UnPack(unpacker)
+	event0, _ := UnPack(unpacker)
 	fmt.Printf("dec(%s) = %s\n", packer, event0)
 
 	packer = bit.NewPack()
 	NewLeaf(one).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	event0 = UnPack(unpacker)
+	event0, _ = UnPack(unpacker)
 	fmt.Printf("dec(%s) = %s\n", packer, event0)
 
 	packer = bit.NewPack()
-	NewLeaf(uint32(13)).Pack(packer)
+	NewLeaf(uint64(13)).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	event0 = UnPack(unpacker)
+	event0, _ = UnPack(unpacker)
 	fmt.Printf("dec(%s) = %s\n", packer, event0)
 
 	// Output:
@@ -262,32 +340,32 @@ func ExampleBitUnPack_decodeEvent_Nodes() {
 	packer := bit.NewPack()
 	NewNode(zero, zero, one).Pack(packer)
 	unpacker := bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	NewNode(zero, one, zero).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	NewNode(zero, one, one).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	NewNode(one, zero, one).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	NewNode(one, one, zero).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	NewNode(one, one, one).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	// Output:
 	// dec(<<0:1, 0:2, 1:1, 0:1, 1:2>>) = (0, 0, 1)
@@ -297,3 +375,226 @@ func ExampleBitUnPack_decodeEvent_Nodes() {
 	// dec(<<0:1, 3:2, 0:1, 1:1, 1:1, 0:1, 1:2, 1:1, 0:1, 1:2>>) = (1, 1, 0)
 	// dec(<<0:1, 3:2, 1:1, 1:1, 0:1, 1:2, 1:1, 0:1, 1:2, 1:1, 0:1, 1:2>>) = (1, 1, 1)
 }
+
+func unpacked(bup *bit.UnPack) string {
+	e, err := UnPack(bup)
+	if err != nil {
+		return err.Error()
+	}
+	return e.String()
+}
+
+func TestUnPackTruncatedEvent(t *testing.T) {
+	for _, data := range [][]byte{nil, {0x70}} {
+		if _, err := UnPack(bit.NewUnPack(data)); !errors.Is(err, bit.ErrTruncated) {
+			t.Errorf("dec(% x) returned %v - expected %v", data, err, bit.ErrTruncated)
+		}
+	}
+}
+
+func TestUnPackEventStopsAtOffset(t *testing.T) {
+	bup := bit.NewUnPack([]byte{0x70})
+	_, err := UnPack(bup)
+	var offsetErr *bit.OffsetError
+	if !errors.As(err, &offsetErr) || offsetErr.Offset != 8 || err != bup.Err() {
+		t.Errorf("dec(70) returned %v - expected %v at bit 8", err, bit.ErrTruncated)
+	}
+}
+
+func TestEventLargeCounters(t *testing.T) {
+	e := NewNode(uint64(1)<<40, zero, math.MaxUint64-uint64(1)<<40)
+	packer := bit.NewPack()
+	e.Pack(packer)
+	if decoded, err := UnPack(bit.NewUnPack(packer.Bytes())); err != nil || !decoded.Equals(e) {
+		t.Errorf("dec(enc(%s)) = %s, %v", e, decoded, err)
+	}
+	if err := e.Validate(); err != nil {
+		t.Errorf("Validate(%s) = %v", e, err)
+	}
+	if overflowing := NewNode(one, zero, math.MaxUint64); overflowing.Validate() != ErrOverflow {
+		t.Errorf("Validate(%s) = %v - expected %v", overflowing, overflowing.Validate(), ErrOverflow)
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	nested := NewNode(one, zero, two)
+	nested.Left = NewNode(zero, zero, one)
+	valid := []*Event{New(), NewNode(zero, zero, one), NewNode(three, two, zero), nested}
+	for _, e := range valid {
+		if err := e.Validate(); err != nil {
+			t.Errorf("validate(%s) returned %v", e, err)
+		}
+	}
+	malformed := []*Event{{IsLeaf: false}, {IsLeaf: false, Left: New()}, {IsLeaf: true, Right: New()}}
+	for _, e := range malformed {
+		if err := e.Validate(); err != ErrMalformedEvent {
+			t.Errorf("validate(%#v) returned %v - expected %v", e, err, ErrMalformedEvent)
+		}
+	}
+	unsunk := NewNode(zero, zero, one)
+	unsunk.Left = NewNode(one, zero, one)
+	notNormalized := []*Event{NewNode(zero, zero, zero), NewNode(one, two, two), NewNode(zero, one, two), unsunk}
+	for _, e := range notNormalized {
+		if err := e.Validate(); err != ErrNotNormalized {
+			t.Errorf("validate(%s) returned %v - expected %v", e, err, ErrNotNormalized)
+		}
+	}
+}
+
+// deepEvent builds a random normalized event tree with 2^depth leaves.
+func deepEvent(depth int, r *rand.Rand) *Event {
+	if depth == 0 {
+		return NewLeaf(uint64(r.Intn(4)))
+	}
+	e := &Event{Value: uint64(r.Intn(3)), Left: deepEvent(depth-1, r), Right: deepEvent(depth-1, r)}
+	return e.Norm()
+}
+
+func BenchmarkJoinDeep(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	e1, e2 := deepEvent(12, r), deepEvent(12, r)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Join(e1, e2)
+	}
+}
+
+func BenchmarkJoinDeepDominated(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	e1 := deepEvent(12, r)
+	e2 := Join(e1, NewLeaf(one))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Join(e2, e1)
+	}
+}
+
+func BenchmarkNormDeep(b *testing.B) {
+	e := deepEvent(12, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		e.Norm()
+	}
+}
+
+func BenchmarkLEQDeep(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	e1 := deepEvent(12, r)
+	e2 := Join(e1, deepEvent(12, r))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !LEQ(e1, e2) {
+			b.Fatalf("%s is not LEQ its join", e1)
+		}
+	}
+}
+
+func BenchmarkCompareDeep(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	e1 := deepEvent(12, r)
+	e2 := Join(e1, deepEvent(12, r))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Compare(e1, e2)
+	}
+}
+
+func TestJoinLaws(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 1000; n++ {
+		e1, e2, e3 := deepEvent(r.Intn(6), r), deepEvent(r.Intn(6), r), deepEvent(r.Intn(6), r)
+		if !Join(e1, e2).Equals(Join(e2, e1)) {
+			t.Fatalf("join(%s, %s) is not commutative", e1, e2)
+		}
+		if !Join(Join(e1, e2), e3).Equals(Join(e1, Join(e2, e3))) {
+			t.Fatalf("join(%s, %s, %s) is not associative", e1, e2, e3)
+		}
+		if !Join(e1, e1).Equals(e1) {
+			t.Fatalf("join(%s, %s) is not idempotent", e1, e1)
+		}
+		joined := Join(e1, e2)
+		if !LEQ(e1, joined) || !LEQ(e2, joined) || joined.Validate() != nil {
+			t.Fatalf("join(%s, %s) = %s is no normalized upper bound", e1, e2, joined)
+		}
+	}
+}
+
+func TestMeetLaws(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 1000; n++ {
+		e1, e2, e3 := deepEvent(r.Intn(6), r), deepEvent(r.Intn(6), r), deepEvent(r.Intn(6), r)
+		if !Meet(e1, e2).Equals(Meet(e2, e1)) {
+			t.Fatalf("meet(%s, %s) is not commutative", e1, e2)
+		}
+		if !Meet(Meet(e1, e2), e3).Equals(Meet(e1, Meet(e2, e3))) {
+			t.Fatalf("meet(%s, %s, %s) is not associative", e1, e2, e3)
+		}
+		if !Meet(e1, e1).Equals(e1) || !Meet(e1, Join(e1, e2)).Equals(e1) {
+			t.Fatalf("meet(%s, %s) is not idempotent or absorbing", e1, e2)
+		}
+		met := Meet(e1, e2)
+		if !LEQ(met, e1) || !LEQ(met, e2) || met.Validate() != nil {
+			t.Fatalf("meet(%s, %s) = %s is no normalized lower bound", e1, e2, met)
+		}
+	}
+}
+
+func TestDiffLaws(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 1000; n++ {
+		from, to := deepEvent(r.Intn(6), r), deepEvent(r.Intn(6), r)
+		delta := Diff(from, to)
+		if delta.Validate() != nil || !LEQ(delta, to) {
+			t.Fatalf("diff(%s, %s) = %s is no normalized part of %s", from, to, delta, to)
+		}
+		if !ApplyDelta(from, delta).Equals(Join(from, to)) {
+			t.Fatalf("apply(%s, diff(%s, %s)) = %s - expected %s", from, from, to, ApplyDelta(from, delta), Join(from, to))
+		}
+		if dominated := Diff(Join(from, to), to); !dominated.Equals(New()) {
+			t.Fatalf("diff(%s, %s) = %s of a dominated event is not 0", Join(from, to), to, dominated)
+		}
+	}
+}
+
+func TestDiffIsCompact(t *testing.T) {
+	from := deepEvent(10, rand.New(rand.NewSource(1)))
+	var raise func(e *Event) *Event
+	raise = func(e *Event) *Event {
+		if e.IsLeaf {
+			return NewLeaf(e.Value + 100)
+		}
+		return Node(e.Value, raise(e.Left), e.Right)
+	}
+	to := raise(from)
+	full, delta := bit.NewPack(), bit.NewPack()
+	to.Pack(full)
+	Diff(from, to).Pack(delta)
+	if delta.BitLen() > 64 || full.BitLen() < 1000 {
+		t.Errorf("delta of a single leaf takes %d of %d bits", delta.BitLen(), full.BitLen())
+	}
+}
+
+func FuzzUnPack(f *testing.F) {
+	for _, e := range []*Event{New(), NewNode(one, zero, two), NewNode(uint64(1)<<40, zero, three)} {
+		packer := bit.NewPack()
+		e.Pack(packer)
+		f.Add(packer.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		e, err := UnPack(bit.NewUnPack(data))
+		if err != nil || e.Validate() != nil {
+			return
+		}
+		packer := bit.NewPack()
+		e.Pack(packer)
+		decoded, err := UnPack(bit.NewUnPack(packer.Bytes()))
+		if err != nil || !decoded.Equals(e) {
+			t.Errorf("dec(enc(%s)) = %s, %v", e, decoded, err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/fgrid/itc/bit"
+)
+
+// JSONReadable selects the form created by Event.MarshalJSON: the base64 string of the bit encoding
+// created by Pack if false (the default) or nested objects, e.g. {"value":1,"left":0,"right":2} for
+// (1, 0, 2), if true. UnmarshalJSON accepts both forms regardless. JSONReadable is meant to be set once
+// during initialization.
+var JSONReadable = false
+
+// Readable wraps an event to be encoded as nested objects regardless of JSONReadable, e.g. within a
+// larger readable document. It decodes both forms like Event.UnmarshalJSON.
+type Readable struct {
+	*Event
+}
+
+// jsonNode is the readable JSON form of an event node, leaves are plain numbers.
+type jsonNode struct {
+	Value uint64   `json:"value"`
+	Left  Readable `json:"left"`
+	Right Readable `json:"right"`
+}
+
+// MarshalJSON encodes the event e in the form selected by JSONReadable.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	if JSONReadable {
+		return Readable{e}.MarshalJSON()
+	}
+	bp := bit.NewPack()
+	e.Pack(bp)
+	return json.Marshal(bp.Bytes())
+}
+
+// UnmarshalJSON decodes the event e from either form created by MarshalJSON. Nodes without both
+// children are rejected with ErrMalformedEvent.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	decoded, err := unmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	*e = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the wrapped event as nested objects.
+func (r Readable) MarshalJSON() ([]byte, error) {
+	if r.IsLeaf {
+		return json.Marshal(r.Value)
+	}
+	return json.Marshal(jsonNode{Value: r.Value, Left: Readable{r.Left}, Right: Readable{r.Right}})
+}
+
+// UnmarshalJSON decodes the wrapped event from either form created by Event.MarshalJSON.
+func (r *Readable) UnmarshalJSON(data []byte) error {
+	decoded, err := unmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	r.Event = decoded
+	return nil
+}
+
+func unmarshalJSON(data []byte) (*Event, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte(`"`)):
+		var packed []byte
+		if err := json.Unmarshal(data, &packed); err != nil {
+			return nil, err
+		}
+		return UnPack(bit.NewUnPack(packed))
+	case bytes.HasPrefix(data, []byte("{")):
+		var node jsonNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		if node.Left.Event == nil || node.Right.Event == nil {
+			return nil, ErrMalformedEvent
+		}
+		return &Event{Value: node.Value, Left: node.Left.Event, Right: node.Right.Event}, nil
+	case bytes.Equal(data, []byte("null")):
+		return nil, ErrMalformedEvent
+	}
+	var value uint64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return NewLeaf(value), nil
+}
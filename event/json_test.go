@@ -0,0 +1,55 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func ExampleEvent_MarshalJSON() {
+	e := NewNode(one, zero, two)
+	e.Left = NewNode(zero, three, zero)
+	compact, _ := json.Marshal(e)
+	JSONReadable = true
+	readable, _ := json.Marshal(e)
+	JSONReadable = false
+	fmt.Printf("%s\n%s\n", compact, readable)
+	decoded := New()
+	err := json.Unmarshal(readable, decoded)
+	fmt.Printf("%s %v\n", decoded, err)
+	// Output:
+	// "eTdA"
+	// {"value":1,"left":{"value":0,"left":3,"right":0},"right":2}
+	// (1, (0, 3, 0), 2) <nil>
+}
+
+func TestEventJSONRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	defer func() { JSONReadable = false }()
+	for n := 0; n < 200; n++ {
+		e := deepEvent(8, r)
+		for _, readable := range []bool{false, true} {
+			JSONReadable = readable
+			data, err := json.Marshal(e)
+			decoded := New()
+			if err != nil || json.Unmarshal(data, decoded) != nil || !decoded.Equals(e) {
+				t.Fatalf("json.Unmarshal(%s) = %s - expected %s (%v)", data, decoded, e, err)
+			}
+		}
+		JSONReadable = false
+		data, err := json.Marshal(Readable{e})
+		var decoded Readable
+		if err != nil || data[0] != '{' && !e.IsLeaf || json.Unmarshal(data, &decoded) != nil || !decoded.Equals(e) {
+			t.Fatalf("json.Unmarshal(%s) = %s - expected readable %s (%v)", data, decoded.Event, e, err)
+		}
+	}
+}
+
+func TestUnmarshalJSONMalformedEvent(t *testing.T) {
+	for _, data := range []string{`null`, `{"value":1,"left":1}`, `{"value":1,"right":{"value":1,"left":0}}`} {
+		if err := json.Unmarshal([]byte(data), New()); err != ErrMalformedEvent {
+			t.Errorf("json.Unmarshal(%s) returned %v - expected %v", data, err, ErrMalformedEvent)
+		}
+	}
+}
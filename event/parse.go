@@ -0,0 +1,72 @@
+package event
+
+import (
+	"errors"
+	"github.com/fgrid/itc/internal/scan"
+	"strconv"
+)
+
+// ErrSyntax is returned when a string does not follow the notation of String.
+var ErrSyntax = errors.New("event: invalid syntax")
+
+// Parse returns the event written in the notation of String, e.g. "(1, 0, (0, 2, 0))". The event is
+// not required to be in normal form.
+func Parse(s string) (*Event, error) {
+	p := parser{scan.New(s, ErrSyntax)}
+	e, err := p.event()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.End(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+type parser struct {
+	*scan.Scanner
+}
+
+func (p parser) event() (*Event, error) {
+	if !p.Accept('(') {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return NewLeaf(value), nil
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Expect(','); err != nil {
+		return nil, err
+	}
+	left, err := p.event()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Expect(','); err != nil {
+		return nil, err
+	}
+	right, err := p.event()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Expect(')'); err != nil {
+		return nil, err
+	}
+	return &Event{Value: value, Left: left, Right: right}, nil
+}
+
+func (p parser) value() (uint64, error) {
+	digits := p.Digits()
+	if digits == "" {
+		return 0, p.Errorf("expected a number")
+	}
+	value, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, p.ErrorAt(ErrSyntax, p.Pos-len(digits), "value %s out of range", digits)
+	}
+	return value, nil
+}
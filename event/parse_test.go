@@ -0,0 +1,26 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleParse() {
+	e, err := Parse("(1, 0, (0, 2, 0))")
+	fmt.Printf("%s %v\n", e, err)
+	e, err = Parse("(1,(0,0,3),0)")
+	fmt.Printf("%s %v\n", e, err)
+	// Output:
+	// (1, 0, (0, 2, 0)) <nil>
+	// (1, (0, 0, 3), 0) <nil>
+}
+
+func TestParseInvalidEvent(t *testing.T) {
+	invalid := []string{"", "(1, 0)", "(1, 0, 1", "1)", "(a, 0, 1)", "18446744073709551616", "-1"}
+	for _, text := range invalid {
+		if e, err := Parse(text); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) = %v, %v - expected %v", text, e, err, ErrSyntax)
+		}
+	}
+}
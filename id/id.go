@@ -1,9 +1,16 @@
 package id
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fgrid/itc/bit"
-	"log"
+)
+
+var (
+	// ErrMalformedID is returned for IDs that are neither a leaf of value 0 or 1 nor a node with two children.
+	ErrMalformedID = errors.New("id: malformed id")
+	// ErrNotNormalized is returned for IDs that are not in normal form (see section "5.2 Normal form").
+	ErrNotNormalized = errors.New("id: id not normalized")
 )
 
 type ID struct {
@@ -49,12 +56,66 @@ func (i *ID) Norm() *ID {
 	return i.asLeaf(i.Left.Value)
 }
 
+// Clone returns a deep copy of the ID i.
+func (i *ID) Clone() *ID {
+	result := &ID{Value: i.Value, IsLeaf: i.IsLeaf}
+	if i.Left != nil {
+		result.Left = i.Left.Clone()
+	}
+	if i.Right != nil {
+		result.Right = i.Right.Clone()
+	}
+	return result
+}
+
+// Equal returns 'true' if the IDs i and o own the same part of the identity space. Both need not be
+// normalized, e.g. (1, 1) is equal to 1.
+func (i *ID) Equal(o *ID) bool {
+	if i.IsLeaf && o.IsLeaf {
+		return i.Value == o.Value
+	}
+	if i.IsLeaf {
+		return i.Equal(o.Left) && i.Equal(o.Right)
+	}
+	if o.IsLeaf {
+		return i.Left.Equal(o) && i.Right.Equal(o)
+	}
+	return i.Left.Equal(o.Left) && i.Right.Equal(o.Right)
+}
+
+// Validate checks that the ID i is well formed, i.e. leaves have the value 0 or 1 and no children and
+// nodes have two children, and that it is in normal form as defined in section "5.2 Normal form".
+func (i *ID) Validate() error {
+	if i.IsLeaf {
+		if i.Left != nil || i.Right != nil || i.Value > 1 {
+			return ErrMalformedID
+		}
+		return nil
+	}
+	if i.Left == nil || i.Right == nil || i.Value != 0 {
+		return ErrMalformedID
+	}
+	if err := i.Left.Validate(); err != nil {
+		return err
+	}
+	if err := i.Right.Validate(); err != nil {
+		return err
+	}
+	if i.Left.IsLeaf && i.Right.IsLeaf && i.Left.Value == i.Right.Value {
+		return ErrNotNormalized
+	}
+	return nil
+}
+
 // Split an ID as defined in section "5.3.2 Fork"
-func (i *ID) Split() (i1, i2 *ID) {
+func (i *ID) Split() (i1, i2 *ID, err error) {
 
 	i1 = New()
 	i2 = New()
 
+	if i.IsLeaf && i.Value > 1 || !i.IsLeaf && (i.Left == nil || i.Right == nil) {
+		return nil, nil, ErrMalformedID
+	}
 	if i.IsLeaf && i.Value == 0 {
 		// split(0) = (0, 0)
 		i1.Value = 0
@@ -69,17 +130,23 @@ func (i *ID) Split() (i1, i2 *ID) {
 	}
 	if (i.Left.IsLeaf && i.Left.Value == 0) && (!i.Right.IsLeaf || i.Right.Value == 1) {
 		// split((0, i)) = ((0, i1), (0, i2)), where (i1, i2) = split(i)
-		r1, r2 := i.Right.Split()
+		r1, r2, err := i.Right.Split()
+		if err != nil {
+			return nil, nil, err
+		}
 		i1.asNodeWithIds(NewWithValue(zero), r1)
 		i2.asNodeWithIds(NewWithValue(zero), r2)
-		return
+		return i1, i2, nil
 	}
 	if (!i.Left.IsLeaf || i.Left.Value == 1) && (i.Right.IsLeaf && i.Right.Value == 0) {
 		// split((i, 0)) = ((i1, 0), (i2, 0)), where (i1, i2) = split(i)
-		l1, l2 := i.Left.Split()
+		l1, l2, err := i.Left.Split()
+		if err != nil {
+			return nil, nil, err
+		}
 		i1.asNodeWithIds(l1, NewWithValue(zero))
 		i2.asNodeWithIds(l2, NewWithValue(zero))
-		return
+		return i1, i2, nil
 	}
 	if (!i.Left.IsLeaf || i.Left.Value == 1) && (!i.Right.IsLeaf || i.Right.Value == 1) {
 		// split((i1, i2)) = ((i1, 0), (0, i2))
@@ -87,8 +154,11 @@ func (i *ID) Split() (i1, i2 *ID) {
 		i2.asNodeWithIds(NewWithValue(zero), i.Right)
 		return
 	}
-	log.Fatalf("unable to split ID with unexpected setup: %s", i.String())
-	return
+	if i.Left.IsLeaf && i.Left.Value == 0 && i.Right.IsLeaf && i.Right.Value == 0 {
+		// (0, 0) should have been normalized to 0
+		return nil, nil, ErrNotNormalized
+	}
+	return nil, nil, ErrMalformedID
 }
 
 func (i *ID) String() string {
@@ -98,6 +168,8 @@ func (i *ID) String() string {
 	return fmt.Sprintf("(%s, %s)", i.Left, i.Right)
 }
 
+// Sum joins the IDs i1 and i2 into i as defined in section "5.3.1 Join" and returns it. The IDs to be
+// joined have to be disjoint (see Overlaps); the sum of overlapping IDs is their union.
 func (i *ID) Sum(i1, i2 *ID) *ID {
 	if i1.IsLeaf && i1.Value == 0 {
 		i.Value = i2.Value
@@ -111,10 +183,26 @@ func (i *ID) Sum(i1, i2 *ID) *ID {
 		i.IsLeaf = i1.IsLeaf
 		return i
 	}
+	if i1.IsLeaf || i2.IsLeaf {
+		// one of them is 1, so both overlap
+		return i.asLeaf(one)
+	}
 	return i.asNodeWithIds(New().Sum(i1.Left, i2.Left), New().Sum(i1.Right, i2.Right)).Norm()
 }
 
-func (i *ID) Pack(p *bit.Pack) {
+// Overlaps returns 'true' if the normalized IDs i1 and i2 share a part of the identity space, so that
+// they must not be joined.
+func Overlaps(i1, i2 *ID) bool {
+	if i1.IsLeaf {
+		return i1.Value == 1 && !(i2.IsLeaf && i2.Value == 0)
+	}
+	if i2.IsLeaf {
+		return i2.Value == 1
+	}
+	return Overlaps(i1.Left, i2.Left) || Overlaps(i1.Right, i2.Right)
+}
+
+func (i *ID) Pack(p bit.Pusher) {
 	if i.IsLeaf {
 		p.Push(zero, two)
 		p.Push(i.Value, one)
@@ -136,21 +224,41 @@ func (i *ID) Pack(p *bit.Pack) {
 	return
 }
 
-func UnPack(bup *bit.UnPack) *ID {
+func UnPack(bup bit.Popper) (*ID, error) {
 	i := New()
-	switch bup.Pop(two) {
+	kind, err := bup.Pop(two)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
 	case 0:
-		i.asLeaf(bup.Pop(one))
+		value, err := bup.Pop(one)
+		if err != nil {
+			return nil, err
+		}
+		i.asLeaf(value)
 	case 1:
-		newID := UnPack(bup)
+		newID, err := UnPack(bup)
+		if err != nil {
+			return nil, err
+		}
 		i.asNodeWithIds(NewWithValue(zero), newID)
 	case 2:
-		newID := UnPack(bup)
+		newID, err := UnPack(bup)
+		if err != nil {
+			return nil, err
+		}
 		i.asNodeWithIds(newID, NewWithValue(zero))
 	case 3:
-		newLeft := UnPack(bup)
-		newRight := UnPack(bup)
+		newLeft, err := UnPack(bup)
+		if err != nil {
+			return nil, err
+		}
+		newRight, err := UnPack(bup)
+		if err != nil {
+			return nil, err
+		}
 		i.asNodeWithIds(newLeft, newRight)
 	}
-	return i
+	return i, nil
 }
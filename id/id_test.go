@@ -1,6 +1,7 @@
 package id
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fgrid/itc/bit"
 	"testing"
@@ -34,7 +35,7 @@ func TestIdNodeStringer(t *testing.T) {
 
 func ExampleSplitId0() {
 	source := NewWithValue(zero)
-	i1, i2 := source.Split()
+	i1, i2, _ := source.Split()
 
 	fmt.Printf("split(%s) = (%s, %s)\n", source, i1, i2)
 	// Output:
@@ -43,7 +44,7 @@ func ExampleSplitId0() {
 
 func ExampleSplitId1() {
 	source := NewWithValue(one)
-	i1, i2 := source.Split()
+	i1, i2, _ := source.Split()
 
 	fmt.Printf("split(%s) = (%s, %s)\n", source, i1, i2)
 	// Output:
@@ -52,7 +53,7 @@ func ExampleSplitId1() {
 
 func ExampleSplitIdLeafNode() {
 	source := New().asNode(zero, one)
-	i1, i2 := source.Split()
+	i1, i2, _ := source.Split()
 
 	fmt.Printf("split(%s) = (%s, %s)\n", source, i1, i2)
 	// Output:
@@ -61,7 +62,7 @@ func ExampleSplitIdLeafNode() {
 
 func ExampleSplitIdNodeLeaf() {
 	source := New().asNode(one, zero)
-	i1, i2 := source.Split()
+	i1, i2, _ := source.Split()
 
 	fmt.Printf("split(%s) = (%s, %s)\n", source, i1, i2)
 	// Output:
@@ -70,7 +71,7 @@ func ExampleSplitIdNodeLeaf() {
 
 func ExampleSplitIdNodeNode() {
 	source := New().asNode(one, one)
-	i1, i2 := source.Split()
+	i1, i2, _ := source.Split()
 
 	fmt.Printf("split(%s) = (%s, %s)\n", source, i1, i2)
 	// Output:
@@ -104,7 +105,7 @@ func ExampleSumIdLeaf() {
 }
 
 func ExampleSumIdNode() {
-	i1, i2 := New().Split()
+	i1, i2, _ := New().Split()
 	fmt.Printf("sum(%s, %s) = %s\n", i1, i2, New().Sum(i1, i2))
 	// Output:
 	// sum((1, 0), (0, 1)) = 1
@@ -147,12 +148,12 @@ func ExampleBitUnPack_decodeID_Leaves() {
 	packer := bit.NewPack()
 	New().asLeaf(zero).Pack(packer)
 	unpacker := bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	New().asLeaf(one).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	// Output:
 	// dec(<<0:2, 0:1>>) = 0
@@ -163,20 +164,135 @@ func ExampleEncDecIdNode() {
 	packer := bit.NewPack()
 	New().asNode(zero, one).Pack(packer)
 	unpacker := bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	New().asNode(one, zero).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	packer = bit.NewPack()
 	New().asNode(one, one).Pack(packer)
 	unpacker = bit.NewUnPack(packer.Pack())
-	fmt.Printf("dec(%s) = %s\n", packer, UnPack(unpacker))
+	fmt.Printf("dec(%s) = %s\n", packer, unpacked(unpacker))
 
 	// Output:
 	// dec(<<1:2, 0:2, 1:1>>) = (0, 1)
 	// dec(<<2:2, 0:2, 1:1>>) = (1, 0)
 	// dec(<<3:2, 0:2, 1:1, 0:2, 1:1>>) = (1, 1)
 }
+
+func unpacked(bup *bit.UnPack) string {
+	i, err := UnPack(bup)
+	if err != nil {
+		return err.Error()
+	}
+	return i.String()
+}
+
+func TestSplitMalformedId(t *testing.T) {
+	for _, source := range []*ID{NewWithValue(two), New().asNode(zero, two), {IsLeaf: false}} {
+		if _, _, err := source.Split(); err != ErrMalformedID {
+			t.Errorf("split(%v) returned %v - expected %v", source, err, ErrMalformedID)
+		}
+	}
+}
+
+func TestSplitNotNormalizedId(t *testing.T) {
+	for _, source := range []*ID{New().asNode(zero, zero), New().asNodeWithIds(NewWithValue(zero), New().asNode(zero, zero))} {
+		if _, _, err := source.Split(); err != ErrNotNormalized {
+			t.Errorf("split(%s) returned %v - expected %v", source, err, ErrNotNormalized)
+		}
+	}
+}
+
+func TestUnPackTruncatedId(t *testing.T) {
+	packer := bit.NewPack()
+	New().asNode(one, one).Pack(packer)
+	data := packer.Pack()
+	if _, err := UnPack(bit.NewUnPack(data[:0])); !errors.Is(err, bit.ErrTruncated) {
+		t.Errorf("dec of empty input returned %v - expected %v", err, bit.ErrTruncated)
+	}
+}
+
+func TestValidateId(t *testing.T) {
+	valid := []*ID{New(), NewWithValue(zero), New().asNode(zero, one), New().asNodeWithIds(New().asNode(one, zero), NewWithValue(one))}
+	for _, i := range valid {
+		if err := i.Validate(); err != nil {
+			t.Errorf("validate(%s) returned %v", i, err)
+		}
+	}
+	malformed := []*ID{NewWithValue(two), New().asNode(zero, two), {IsLeaf: false}, {IsLeaf: true, Left: New()}, {Value: one, Left: New(), Right: NewWithValue(zero)}}
+	for _, i := range malformed {
+		if err := i.Validate(); err != ErrMalformedID {
+			t.Errorf("validate(%#v) returned %v - expected %v", i, err, ErrMalformedID)
+		}
+	}
+	notNormalized := []*ID{New().asNode(zero, zero), New().asNode(one, one), New().asNodeWithIds(NewWithValue(zero), New().asNode(one, one))}
+	for _, i := range notNormalized {
+		if err := i.Validate(); err != ErrNotNormalized {
+			t.Errorf("validate(%s) returned %v - expected %v", i, err, ErrNotNormalized)
+		}
+	}
+}
+
+func ExampleOverlaps() {
+	i1, i2, _ := New().Split()
+	i3, i4, _ := i2.Split()
+	fmt.Printf("Overlaps(%s, %s) = %t\n", i1, i2, Overlaps(i1, i2))
+	fmt.Printf("Overlaps(%s, %s) = %t\n", i3, i4, Overlaps(i3, i4))
+	fmt.Printf("Overlaps(%s, %s) = %t\n", i2, i4, Overlaps(i2, i4))
+	fmt.Printf("Overlaps(%s, %s) = %t\n", New(), i1, Overlaps(New(), i1))
+	fmt.Printf("Overlaps(%s, %s) = %t\n", NewWithValue(zero), New(), Overlaps(NewWithValue(zero), New()))
+	// Output:
+	// Overlaps((1, 0), (0, 1)) = false
+	// Overlaps((0, (1, 0)), (0, (0, 1))) = false
+	// Overlaps((0, 1), (0, (0, 1))) = true
+	// Overlaps(1, (1, 0)) = true
+	// Overlaps(0, 1) = false
+}
+
+func ExampleID_Sum_overlapping() {
+	i1 := New().asNode(one, zero)
+	i2 := New().asNodeWithIds(New().asNode(zero, one), NewWithValue(one))
+	fmt.Printf("sum(%s, %s) = %s\n", i1, i2, New().Sum(i1, i2))
+	// Output:
+	// sum((1, 0), ((0, 1), 1)) = 1
+}
+
+func TestIdCloneAndEqual(t *testing.T) {
+	source := New().asNodeWithIds(New().asNode(zero, one), NewWithValue(one))
+	clone := source.Clone()
+	if !clone.Equal(source) || clone == source || clone.Left == source.Left {
+		t.Errorf("clone %s is not a deep copy of %s", clone, source)
+	}
+	if source.Equal(New()) || New().Equal(source) || source.Equal(New().asNode(one, zero)) {
+		t.Errorf("%s should only equal itself", source)
+	}
+	if !New().Equal(New().asNode(one, one)) || !New().asNode(zero, zero).Equal(NewWithValue(zero)) {
+		t.Error("not normalized ids should equal their normal form")
+	}
+}
+
+func FuzzUnPack(f *testing.F) {
+	for _, i := range []*ID{New(), NewWithValue(zero), New().asNodeWithIds(New().asNode(one, zero), NewWithValue(one))} {
+		packer := bit.NewPack()
+		i.Pack(packer)
+		f.Add(packer.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		i, err := UnPack(bit.NewUnPack(data))
+		if err != nil || i.Validate() != nil {
+			return
+		}
+		packer := bit.NewPack()
+		i.Pack(packer)
+		if decoded, err := UnPack(bit.NewUnPack(packer.Bytes())); err != nil || !decoded.Equal(i) {
+			t.Errorf("dec(enc(%s)) = %s, %v", i, decoded, err)
+		}
+		i1, i2, err := i.Split()
+		if err != nil || Overlaps(i1, i2) || !New().Sum(i1, i2).Equal(i) {
+			t.Errorf("split(%s) = %s, %s, %v is no partition", i, i1, i2, err)
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package id
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+var (
+	// ErrInvalidInterval is returned for intervals that are empty, reversed, not within [0, 1) or have an
+	// endpoint that is not a dyadic rational (a fraction with a power of two as denominator).
+	ErrInvalidInterval = errors.New("id: invalid interval")
+	// ErrInvalidWeights is returned for weights of a split that are negative or do not sum up to a
+	// positive value of at most an eighth of math.MaxInt.
+	ErrInvalidWeights = errors.New("id: invalid weights")
+	// ErrOverlapping is returned for IDs to be rebalanced that share a part of the identity space.
+	ErrOverlapping = errors.New("id: overlapping ids")
+)
+
+// Interval is the half-open part [Start, End) of the identity space [0, 1).
+type Interval struct {
+	Start, End *big.Rat
+}
+
+func (in Interval) String() string {
+	return fmt.Sprintf("[%s, %s)", in.Start.RatString(), in.End.RatString())
+}
+
+// Fraction returns the measure of the part of the identity space [0, 1) owned by the ID i.
+func (i *ID) Fraction() *big.Rat {
+	if i.IsLeaf {
+		return big.NewRat(int64(i.Value), 1)
+	}
+	sum := new(big.Rat).Add(i.Left.Fraction(), i.Right.Fraction())
+	return sum.Quo(sum, big.NewRat(2, 1))
+}
+
+// Intervals returns the ordered, disjoint and maximal intervals of the identity space owned by the ID i.
+func (i *ID) Intervals() []Interval {
+	return i.intervals(nil, new(big.Rat), big.NewRat(1, 1))
+}
+
+// intervals appends the intervals of the ID i mapped to the part of the identity space starting at
+// start with the given width, merging adjacent ones.
+func (i *ID) intervals(result []Interval, start, width *big.Rat) []Interval {
+	if i.IsLeaf {
+		if i.Value == 0 {
+			return result
+		}
+		end := new(big.Rat).Add(start, width)
+		if n := len(result); n > 0 && result[n-1].End.Cmp(start) == 0 {
+			result[n-1].End = end
+			return result
+		}
+		return append(result, Interval{Start: new(big.Rat).Set(start), End: end})
+	}
+	half := new(big.Rat).Quo(width, big.NewRat(2, 1))
+	result = i.Left.intervals(result, start, half)
+	return i.Right.intervals(result, new(big.Rat).Add(start, half), half)
+}
+
+// FromIntervals returns the normalized ID owning the union of the given intervals, which may overlap.
+// ErrInvalidInterval is returned for an interval that cannot be part of an ID.
+func FromIntervals(intervals ...Interval) (*ID, error) {
+	merged := make([]Interval, 0, len(intervals))
+	for _, in := range intervals {
+		if in.Start == nil || in.End == nil || !dyadic(in.Start) || !dyadic(in.End) ||
+			in.Start.Sign() < 0 || in.End.Cmp(big.NewRat(1, 1)) > 0 || in.Start.Cmp(in.End) >= 0 {
+			return nil, ErrInvalidInterval
+		}
+		merged = append(merged, in)
+	}
+	sort.Slice(merged, func(a, b int) bool { return merged[a].Start.Cmp(merged[b].Start) < 0 })
+	n := 0
+	for _, in := range merged {
+		if n > 0 && merged[n-1].End.Cmp(in.Start) >= 0 {
+			if merged[n-1].End.Cmp(in.End) < 0 {
+				merged[n-1].End = in.End
+			}
+			continue
+		}
+		merged[n] = in
+		n++
+	}
+	return fromIntervals(merged[:n], new(big.Rat), big.NewRat(1, 1)), nil
+}
+
+// fromIntervals builds the ID of the part [start, end) of the identity space owned by the disjoint,
+// ordered and non-adjacent intervals. Their endpoints are dyadic, so the recursion ends.
+func fromIntervals(intervals []Interval, start, end *big.Rat) *ID {
+	for _, in := range intervals {
+		if in.Start.Cmp(end) >= 0 || in.End.Cmp(start) <= 0 {
+			continue
+		}
+		if in.Start.Cmp(start) <= 0 && in.End.Cmp(end) >= 0 {
+			return New()
+		}
+		mid := new(big.Rat).Add(start, end)
+		mid.Quo(mid, big.NewRat(2, 1))
+		return New().asNodeWithIds(fromIntervals(intervals, start, mid), fromIntervals(intervals, mid, end)).Norm()
+	}
+	return NewWithValue(zero)
+}
+
+// dyadic returns 'true' if the denominator of r is a power of two.
+func dyadic(r *big.Rat) bool {
+	d := r.Denom()
+	return d.TrailingZeroBits() == uint(d.BitLen()-1)
+}
+
+// SplitWeighted splits the ID i into disjoint IDs, one per weight, whose union is i and whose
+// fractions are approximately proportional to the weights. The parts are cut from the intervals of i
+// in order, each cut at the shallowest dyadic point less than an eighth of a weight unit (the fraction
+// of i divided by the sum of the weights) away from its exact position. So each part deviates by less
+// than a quarter of a unit from its exact share, and every positive weight gets a part of the identity
+// space if i owns any.
+func (i *ID) SplitWeighted(weights ...int) ([]*ID, error) {
+	total := 0
+	for _, w := range weights {
+		// the tolerance below is computed from 8 times the sum, which has to fit into an int
+		if w < 0 || w > math.MaxInt/8-total {
+			return nil, ErrInvalidWeights
+		}
+		total += w
+	}
+	if total <= 0 {
+		return nil, ErrInvalidWeights
+	}
+	if err := i.Validate(); err != nil {
+		return nil, err
+	}
+	parts := make([]*ID, len(weights))
+	owned, measure := i.Intervals(), i.Fraction()
+	if measure.Sign() == 0 {
+		for k := range parts {
+			parts[k] = NewWithValue(zero)
+		}
+		return parts, nil
+	}
+	tolerance := new(big.Rat).Quo(measure, big.NewRat(int64(8*total), 1))
+	start, sum := new(big.Rat), 0
+	for k, w := range weights {
+		sum += w
+		end := measure
+		if sum < total {
+			target := new(big.Rat).Mul(measure, big.NewRat(int64(sum), int64(total)))
+			end = shallowest(new(big.Rat).Sub(target, tolerance), new(big.Rat).Add(target, tolerance))
+		}
+		part, err := FromIntervals(cut(owned, start, end)...)
+		if err != nil {
+			return nil, err
+		}
+		parts[k], start = part, end
+	}
+	return parts, nil
+}
+
+// Rebalance returns replacements for the disjoint IDs, each owning exactly the fraction of the ID at
+// the same index, that together own the union of the IDs but are as shallow as possible. The
+// fractions are split into aligned blocks of powers of two, which are placed like by a buddy
+// allocator: the largest blocks first, each into the first of the smallest free blocks of the union it
+// fits in. A block larger than every free block is split in halves. Owners of equal fractions then
+// swap parts so that each keeps as much of its current identity space as possible. If the
+// replacements would not be shallower in total than the IDs, the IDs themselves are returned, so that
+// rebalancing twice changes nothing. ErrOverlapping is returned if the IDs are not disjoint.
+func Rebalance(ids ...*ID) ([]*ID, error) {
+	union := NewWithValue(zero)
+	fractions := make([]*big.Rat, len(ids))
+	var owners []int
+	for k, i := range ids {
+		if err := i.Validate(); err != nil {
+			return nil, err
+		}
+		if Overlaps(union, i) {
+			return nil, ErrOverlapping
+		}
+		union = New().Sum(union, i)
+		if fractions[k] = i.Fraction(); fractions[k].Sign() > 0 {
+			owners = append(owners, k)
+		}
+	}
+	sort.SliceStable(owners, func(a, b int) bool { return fractions[owners[a]].Cmp(fractions[owners[b]]) > 0 })
+	parts, err := allocate(union, owners, fractions)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*ID, len(ids))
+	for k := range result {
+		result[k] = NewWithValue(zero)
+	}
+	for start := 0; start < len(owners); {
+		end := start + 1
+		for end < len(owners) && fractions[owners[end]].Cmp(fractions[owners[start]]) == 0 {
+			end++
+		}
+		keep(ids, owners[start:end], parts, result)
+		start = end
+	}
+	before, after := 0, 0
+	for k := range ids {
+		before, after = before+ids[k].Depth(), after+result[k].Depth()
+	}
+	if after >= before {
+		return append([]*ID(nil), ids...), nil
+	}
+	return result, nil
+}
+
+// block is the aligned part of the identity space of width 2^-level starting at start, either free
+// or requested by the ID at index owner.
+type block struct {
+	start *big.Rat
+	level int
+	owner int
+}
+
+// allocate places the blocks of the binary expansions of the fractions of the owners within the
+// union, which they fill exactly, and returns the part of each owner.
+func allocate(union *ID, owners []int, fractions []*big.Rat) (map[int]*ID, error) {
+	var requests []block
+	for _, k := range owners {
+		requests = append(requests, blocksOf(k, fractions[k])...)
+	}
+	byLevel := func() {
+		sort.SliceStable(requests, func(a, b int) bool { return requests[a].level < requests[b].level })
+	}
+	byLevel()
+	free := union.blocks(nil, new(big.Rat), 0)
+	owned := map[int][]Interval{}
+	for len(requests) > 0 {
+		request := requests[0]
+		requests = requests[1:]
+		fit := -1
+		for n, f := range free {
+			if f.level <= request.level && (fit < 0 || f.level > free[fit].level ||
+				f.level == free[fit].level && f.start.Cmp(free[fit].start) < 0) {
+				fit = n
+			}
+		}
+		if fit < 0 {
+			half := block{owner: request.owner, level: request.level + 1}
+			requests = append(requests, half, half)
+			byLevel()
+			continue
+		}
+		f := free[fit]
+		free = append(free[:fit], free[fit+1:]...)
+		// take the left part of the free block and free the right buddies of each level
+		for level := f.level + 1; level <= request.level; level++ {
+			free = append(free, block{start: new(big.Rat).Add(f.start, width(level)), level: level})
+		}
+		owned[request.owner] = append(owned[request.owner],
+			Interval{Start: f.start, End: new(big.Rat).Add(f.start, width(request.level))})
+	}
+	parts := map[int]*ID{}
+	for k, intervals := range owned {
+		part, err := FromIntervals(intervals...)
+		if err != nil {
+			return nil, err
+		}
+		parts[k] = part
+	}
+	return parts, nil
+}
+
+// blocks appends the aligned blocks owned by the ID i, which is mapped to the block at start of the
+// given level, in order.
+func (i *ID) blocks(result []block, start *big.Rat, level int) []block {
+	if i.IsLeaf {
+		if i.Value == 0 {
+			return result
+		}
+		return append(result, block{start: start, level: level})
+	}
+	result = i.Left.blocks(result, start, level+1)
+	return i.Right.blocks(result, new(big.Rat).Add(start, width(level+1)), level+1)
+}
+
+// blocksOf returns the blocks of the binary expansion of the dyadic fraction f requested by owner,
+// the largest first.
+func blocksOf(owner int, f *big.Rat) []block {
+	var result []block
+	num, exp := f.Num(), f.Denom().BitLen()-1
+	for b := num.BitLen() - 1; b >= 0; b-- {
+		if num.Bit(b) == 1 {
+			result = append(result, block{owner: owner, level: exp - b})
+		}
+	}
+	return result
+}
+
+// width returns the width 2^-level of the blocks of the given level.
+func width(level int) *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), uint(level)))
+}
+
+// keep assigns the parts allocated for the owners of equal fractions to them in result, each part to
+// the owner whose current ID has most of it in common.
+func keep(ids []*ID, owners []int, parts map[int]*ID, result []*ID) {
+	free := make([]*ID, 0, len(owners))
+	for _, k := range owners {
+		free = append(free, parts[k])
+	}
+	assigned := map[int]bool{}
+	for range owners {
+		best, bestPart, bestCommon := -1, -1, new(big.Rat)
+		for _, k := range owners {
+			if assigned[k] {
+				continue
+			}
+			for n, part := range free {
+				if part == nil {
+					continue
+				}
+				if c := common(ids[k], part); best < 0 || c.Cmp(bestCommon) > 0 {
+					best, bestPart, bestCommon = k, n, c
+				}
+			}
+		}
+		result[best], free[bestPart], assigned[best] = free[bestPart], nil, true
+	}
+}
+
+// common returns the measure of the part of the identity space owned by both IDs a and b.
+func common(a, b *ID) *big.Rat {
+	if a.IsLeaf && a.Value == 0 || b.IsLeaf && b.Value == 0 {
+		return new(big.Rat)
+	}
+	if a.IsLeaf && b.IsLeaf {
+		return big.NewRat(1, 1)
+	}
+	aLeft, aRight := a.Halves()
+	bLeft, bRight := b.Halves()
+	sum := new(big.Rat).Add(common(aLeft, bLeft), common(aRight, bRight))
+	return sum.Quo(sum, big.NewRat(2, 1))
+}
+
+// Halves returns the IDs the ID i has in the left and the right half of its part of the identity
+// space, which are i itself for a leaf.
+func (i *ID) Halves() (left, right *ID) {
+	if i.IsLeaf {
+		return i, i
+	}
+	return i.Left, i.Right
+}
+
+// Depth returns the number of levels of nodes of the ID i.
+func (i *ID) Depth() int {
+	if i.IsLeaf {
+		return 0
+	}
+	left, right := i.Left.Depth(), i.Right.Depth()
+	if left > right {
+		return left + 1
+	}
+	return right + 1
+}
+
+// shallowest returns the dyadic rational with the smallest denominator within [from, to).
+func shallowest(from, to *big.Rat) *big.Rat {
+	scale := big.NewInt(1)
+	for {
+		// the smallest multiple of 1/scale not below from
+		n := new(big.Int).Mul(from.Num(), scale)
+		r := new(big.Int)
+		n.QuoRem(n, from.Denom(), r)
+		if r.Sign() > 0 {
+			n.Add(n, big.NewInt(1))
+		}
+		candidate := new(big.Rat).SetFrac(n, new(big.Int).Set(scale))
+		if candidate.Cmp(to) < 0 {
+			return candidate
+		}
+		scale.Lsh(scale, 1)
+	}
+}
+
+// cut returns the parts of the ordered intervals that lie between the measures from and to, with
+// the measure counted from the start of the first interval.
+func cut(intervals []Interval, from, to *big.Rat) []Interval {
+	var result []Interval
+	covered := new(big.Rat)
+	for _, in := range intervals {
+		length := new(big.Rat).Sub(in.End, in.Start)
+		next := new(big.Rat).Add(covered, length)
+		start, end := maxRat(from, covered), minRat(to, next)
+		if start.Cmp(end) < 0 {
+			result = append(result, Interval{
+				Start: new(big.Rat).Add(in.Start, new(big.Rat).Sub(start, covered)),
+				End:   new(big.Rat).Add(in.Start, new(big.Rat).Sub(end, covered)),
+			})
+		}
+		covered = next
+	}
+	return result
+}
+
+func maxRat(r1, r2 *big.Rat) *big.Rat {
+	if r1.Cmp(r2) > 0 {
+		return r1
+	}
+	return r2
+}
+
+func minRat(r1, r2 *big.Rat) *big.Rat {
+	if r1.Cmp(r2) < 0 {
+		return r1
+	}
+	return r2
+}
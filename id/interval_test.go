@@ -0,0 +1,147 @@
+package id
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func ExampleID_Intervals() {
+	i, _ := Parse("((0, 1), (1, (1, 0)))")
+	fmt.Printf("%s owns %s in %v\n", i, i.Fraction().RatString(), i.Intervals())
+	// Output:
+	// ((0, 1), (1, (1, 0))) owns 5/8 in [[1/4, 7/8)]
+}
+
+func ExampleFromIntervals() {
+	i, _ := FromIntervals(
+		Interval{Start: big.NewRat(0, 1), End: big.NewRat(1, 4)},
+		Interval{Start: big.NewRat(1, 8), End: big.NewRat(1, 2)},
+		Interval{Start: big.NewRat(3, 4), End: big.NewRat(1, 1)})
+	fmt.Println(i)
+	// Output:
+	// (1, (0, 1))
+}
+
+func TestFromIntervalsInvalid(t *testing.T) {
+	invalid := []Interval{
+		{Start: big.NewRat(0, 1), End: big.NewRat(1, 3)},
+		{Start: big.NewRat(1, 2), End: big.NewRat(1, 2)},
+		{Start: big.NewRat(1, 2), End: big.NewRat(1, 4)},
+		{Start: big.NewRat(-1, 2), End: big.NewRat(1, 2)},
+		{Start: big.NewRat(1, 2), End: big.NewRat(3, 2)},
+		{Start: big.NewRat(0, 1)},
+	}
+	for _, in := range invalid {
+		if _, err := FromIntervals(in); err != ErrInvalidInterval {
+			t.Errorf("FromIntervals(%v) returned %v - expected %v", in, err, ErrInvalidInterval)
+		}
+	}
+}
+
+// randomID returns a random normalized ID of at most the given depth.
+func randomID(depth int, r *rand.Rand) *ID {
+	if depth == 0 || r.Intn(3) == 0 {
+		return NewWithValue(uint32(r.Intn(2)))
+	}
+	return New().asNodeWithIds(randomID(depth-1, r), randomID(depth-1, r)).Norm()
+}
+
+func TestIntervalsRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 1000; n++ {
+		i := randomID(8, r)
+		intervals := i.Intervals()
+		sum := new(big.Rat)
+		for k, in := range intervals {
+			sum.Add(sum, new(big.Rat).Sub(in.End, in.Start))
+			if k > 0 && intervals[k-1].End.Cmp(in.Start) >= 0 {
+				t.Fatalf("intervals %v of %s are not disjoint and maximal", intervals, i)
+			}
+		}
+		if sum.Cmp(i.Fraction()) != 0 {
+			t.Fatalf("intervals %v of %s do not measure %s", intervals, i, i.Fraction().RatString())
+		}
+		if back, err := FromIntervals(intervals...); err != nil || back.String() != i.String() {
+			t.Fatalf("FromIntervals(%v) = %s, %v - expected %s", intervals, back, err, i)
+		}
+	}
+}
+
+func TestSplitWeightedInvalid(t *testing.T) {
+	for _, weights := range [][]int{nil, {0, 0}, {1, -1, 2}, {math.MaxInt, math.MaxInt, 3}, {math.MaxInt / 4, 1}, {math.MaxInt/8 + 1}} {
+		if _, err := New().SplitWeighted(weights...); err != ErrInvalidWeights {
+			t.Errorf("SplitWeighted(%v) returned %v - expected %v", weights, err, ErrInvalidWeights)
+		}
+	}
+	if parts, err := New().SplitWeighted(math.MaxInt/16, math.MaxInt/16); err != nil || parts[0].String() != "(1, 0)" || parts[1].String() != "(0, 1)" {
+		t.Errorf("SplitWeighted of huge weights = %v, %v - expected [(1, 0) (0, 1)]", parts, err)
+	}
+	if parts, err := NewWithValue(zero).SplitWeighted(1, 1); err != nil || parts[0].Value != 0 || parts[1].Value != 0 {
+		t.Errorf("SplitWeighted(1, 1) of 0 = %v, %v - expected 0, 0", parts, err)
+	}
+}
+
+func ExampleRebalance() {
+	a, _ := Parse("((0, 1), (0, 1))")
+	b, _ := Parse("((1, 0), (1, 0))")
+	ids, _ := Rebalance(a, b, NewWithValue(zero))
+	fmt.Println(ids)
+	// Output:
+	// [(1, 0) (0, 1) 0]
+}
+
+func TestRebalance(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 2000; n++ {
+		ids := []*ID{randomID(8, r)}
+		for len(ids) < 1+r.Intn(8) {
+			k := r.Intn(len(ids))
+			i1, i2, _ := ids[k].Split()
+			ids[k] = i1
+			ids = append(ids, i2)
+		}
+		rebalanced, err := Rebalance(ids...)
+		if err != nil {
+			t.Fatalf("Rebalance(%v) returned %v", ids, err)
+		}
+		union, sum := NewWithValue(zero), NewWithValue(zero)
+		before, after := 0, 0
+		for k, i := range rebalanced {
+			if i.Validate() != nil || Overlaps(sum, i) || i.Fraction().Cmp(ids[k].Fraction()) != 0 {
+				t.Fatalf("Rebalance(%v) = %v does not keep the fraction of %s", ids, rebalanced, ids[k])
+			}
+			union, sum = New().Sum(union, ids[k]), New().Sum(sum, i)
+			before, after = before+ids[k].Depth(), after+i.Depth()
+		}
+		if !sum.Equal(union) {
+			t.Fatalf("Rebalance(%v) = %v does not own the union %s", ids, rebalanced, union)
+		}
+		if after > before {
+			t.Fatalf("Rebalance(%v) = %v is deeper", ids, rebalanced)
+		}
+		if again, _ := Rebalance(rebalanced...); fmt.Sprint(again) != fmt.Sprint(rebalanced) {
+			t.Fatalf("Rebalance(%v) = %v is not stable but becomes %v", ids, rebalanced, again)
+		}
+	}
+	if _, err := Rebalance(New(), New().asNode(zero, one)); err != ErrOverlapping {
+		t.Errorf("Rebalance of overlapping ids returned %v - expected %v", err, ErrOverlapping)
+	}
+}
+
+func TestRebalanceKeepsShallowIDs(t *testing.T) {
+	for _, texts := range [][]string{
+		{"((1, (1, 0)), 1)", "((0, (0, 1)), 0)"},
+		{"(((1, 0), 0), 0)", "(((0, 1), 0), 0)", "((0, 1), 0)", "(0, (1, 0))"},
+	} {
+		ids := make([]*ID, len(texts))
+		for k, text := range texts {
+			ids[k], _ = Parse(text)
+		}
+		if rebalanced, err := Rebalance(ids...); err != nil || fmt.Sprint(rebalanced) != fmt.Sprint(ids) {
+			t.Errorf("Rebalance(%v) = %v, %v - expected the ids unchanged", ids, rebalanced, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package id
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/fgrid/itc/bit"
+)
+
+// JSONReadable selects the form created by ID.MarshalJSON: the base64 string of the bit encoding
+// created by Pack if false (the default) or nested objects, e.g. {"left":0,"right":1} for (0, 1), if
+// true. UnmarshalJSON accepts both forms regardless. JSONReadable is meant to be set once during
+// initialization.
+var JSONReadable = false
+
+// Readable wraps an ID to be encoded as nested objects regardless of JSONReadable, e.g. within a
+// larger readable document. It decodes both forms like ID.UnmarshalJSON.
+type Readable struct {
+	*ID
+}
+
+// jsonNode is the readable JSON form of an ID node, leaves are plain numbers.
+type jsonNode struct {
+	Left  Readable `json:"left"`
+	Right Readable `json:"right"`
+}
+
+// MarshalJSON encodes the ID i in the form selected by JSONReadable.
+func (i *ID) MarshalJSON() ([]byte, error) {
+	if JSONReadable {
+		return Readable{i}.MarshalJSON()
+	}
+	bp := bit.NewPack()
+	i.Pack(bp)
+	return json.Marshal(bp.Bytes())
+}
+
+// UnmarshalJSON decodes the ID i from either form created by MarshalJSON. Leaves other than 0 or 1 and
+// nodes without both children are rejected with ErrMalformedID.
+func (i *ID) UnmarshalJSON(data []byte) error {
+	decoded, err := unmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	*i = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the wrapped ID as nested objects.
+func (r Readable) MarshalJSON() ([]byte, error) {
+	if r.IsLeaf {
+		return json.Marshal(r.Value)
+	}
+	return json.Marshal(jsonNode{Left: Readable{r.Left}, Right: Readable{r.Right}})
+}
+
+// UnmarshalJSON decodes the wrapped ID from either form created by ID.MarshalJSON.
+func (r *Readable) UnmarshalJSON(data []byte) error {
+	decoded, err := unmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	r.ID = decoded
+	return nil
+}
+
+func unmarshalJSON(data []byte) (*ID, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte(`"`)):
+		var packed []byte
+		if err := json.Unmarshal(data, &packed); err != nil {
+			return nil, err
+		}
+		return UnPack(bit.NewUnPack(packed))
+	case bytes.HasPrefix(data, []byte("{")):
+		var node jsonNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		if node.Left.ID == nil || node.Right.ID == nil {
+			return nil, ErrMalformedID
+		}
+		return New().asNodeWithIds(node.Left.ID, node.Right.ID), nil
+	case bytes.Equal(data, []byte("null")):
+		return nil, ErrMalformedID
+	}
+	var value uint32
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if value > 1 {
+		return nil, ErrMalformedID
+	}
+	return NewWithValue(value), nil
+}
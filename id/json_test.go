@@ -0,0 +1,54 @@
+package id
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func ExampleID_MarshalJSON() {
+	i := New().asNodeWithIds(New().asNode(zero, one), NewWithValue(one))
+	compact, _ := json.Marshal(i)
+	JSONReadable = true
+	readable, _ := json.Marshal(i)
+	JSONReadable = false
+	fmt.Printf("%s\n%s\n", compact, readable)
+	decoded := New()
+	err := json.Unmarshal(readable, decoded)
+	fmt.Printf("%s %v\n", decoded, err)
+	// Output:
+	// "0kA="
+	// {"left":{"left":0,"right":1},"right":1}
+	// ((0, 1), 1) <nil>
+}
+
+func TestIDJSONRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	defer func() { JSONReadable = false }()
+	for n := 0; n < 200; n++ {
+		i := randomID(8, r)
+		for _, readable := range []bool{false, true} {
+			JSONReadable = readable
+			data, err := json.Marshal(i)
+			decoded := New()
+			if err != nil || json.Unmarshal(data, decoded) != nil || !decoded.Equal(i) {
+				t.Fatalf("json.Unmarshal(%s) = %s - expected %s (%v)", data, decoded, i, err)
+			}
+		}
+		JSONReadable = false
+		data, err := json.Marshal(Readable{i})
+		var decoded Readable
+		if err != nil || data[0] != '{' && !i.IsLeaf || json.Unmarshal(data, &decoded) != nil || !decoded.Equal(i) {
+			t.Fatalf("json.Unmarshal(%s) = %s - expected readable %s (%v)", data, decoded.ID, i, err)
+		}
+	}
+}
+
+func TestUnmarshalJSONMalformedId(t *testing.T) {
+	for _, data := range []string{`2`, `null`, `{"left":1}`, `{"left":null,"right":0}`, `{"left":0,"right":{"left":3,"right":0}}`} {
+		if err := json.Unmarshal([]byte(data), New()); err != ErrMalformedID {
+			t.Errorf("json.Unmarshal(%s) returned %v - expected %v", data, err, ErrMalformedID)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package id
+
+import (
+	"errors"
+	"github.com/fgrid/itc/internal/scan"
+	"strconv"
+)
+
+// ErrSyntax is returned when a string does not follow the notation of String.
+var ErrSyntax = errors.New("id: invalid syntax")
+
+// Parse returns the ID written in the notation of String, e.g. "((0, 1), 1)". Leaves other than 0 or 1
+// are rejected with ErrMalformedID, but the ID is not required to be in normal form.
+func Parse(s string) (*ID, error) {
+	p := parser{scan.New(s, ErrSyntax)}
+	i, err := p.id()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.End(); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+type parser struct {
+	*scan.Scanner
+}
+
+func (p parser) id() (*ID, error) {
+	if p.Accept('(') {
+		left, err := p.id()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.Expect(','); err != nil {
+			return nil, err
+		}
+		right, err := p.id()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.Expect(')'); err != nil {
+			return nil, err
+		}
+		return New().asNodeWithIds(left, right), nil
+	}
+	digits := p.Digits()
+	if digits == "" {
+		return nil, p.Errorf("expected 0, 1 or '('")
+	}
+	value, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil || value > 1 {
+		return nil, p.ErrorAt(ErrMalformedID, p.Pos-len(digits), "leaf %s is neither 0 nor 1", digits)
+	}
+	return NewWithValue(uint32(value)), nil
+}
@@ -0,0 +1,37 @@
+package id
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleParse() {
+	i, err := Parse("((0, 1), 1)")
+	fmt.Printf("%s %v\n", i, err)
+	i, err = Parse(" ( 1,( 0 ,1 ) ) ")
+	fmt.Printf("%s %v\n", i, err)
+	// Output:
+	// ((0, 1), 1) <nil>
+	// (1, (0, 1)) <nil>
+}
+
+func TestParseInvalidId(t *testing.T) {
+	invalid := []struct {
+		text string
+		err  error
+	}{
+		{"", ErrSyntax},
+		{"(1, 0", ErrSyntax},
+		{"(1 0)", ErrSyntax},
+		{"1)", ErrSyntax},
+		{"(1, 0, 1)", ErrSyntax},
+		{"2", ErrMalformedID},
+		{"(0, 99999999999)", ErrMalformedID},
+	}
+	for _, test := range invalid {
+		if i, err := Parse(test.text); !errors.Is(err, test.err) {
+			t.Errorf("Parse(%q) = %v, %v - expected %v", test.text, i, err, test.err)
+		}
+	}
+}
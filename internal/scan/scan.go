@@ -0,0 +1,99 @@
+// Package scan provides the cursor shared by the parsers of the notation of IDs, events and stamps.
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error is an error at an offset of the parsed text. It wraps the ErrSyntax of the package whose
+// notation was violated, or another error of that package such as a value out of its domain.
+type Error struct {
+	Err    error
+	Offset int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v at offset %d: %s", e.Err, e.Offset, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Shift returns err with its offset moved by the given amount if it is an *Error, e.g. to relate the
+// error of parsing a substring to the whole text. Other errors are returned as they are.
+func Shift(err error, by int) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return err
+	}
+	return &Error{Err: e.Err, Offset: e.Offset + by, Msg: e.Msg}
+}
+
+// Scanner is a position within a text. Its errors wrap the given syntax error.
+type Scanner struct {
+	Text   string
+	Pos    int
+	syntax error
+}
+
+func New(text string, syntax error) *Scanner {
+	return &Scanner{Text: text, syntax: syntax}
+}
+
+// SkipSpace advances the position past white space.
+func (s *Scanner) SkipSpace() {
+	for s.Pos < len(s.Text) && strings.IndexByte(" \t\r\n", s.Text[s.Pos]) >= 0 {
+		s.Pos++
+	}
+}
+
+// Accept skips white space and the byte c, and returns 'true' if c was found.
+func (s *Scanner) Accept(c byte) bool {
+	s.SkipSpace()
+	if s.Pos < len(s.Text) && s.Text[s.Pos] == c {
+		s.Pos++
+		return true
+	}
+	return false
+}
+
+// Expect skips white space and the byte c, which has to be next.
+func (s *Scanner) Expect(c byte) error {
+	if !s.Accept(c) {
+		return s.Errorf("expected %q", c)
+	}
+	return nil
+}
+
+// Digits skips white space and returns the decimal digits following it, if any.
+func (s *Scanner) Digits() string {
+	s.SkipSpace()
+	start := s.Pos
+	for s.Pos < len(s.Text) && s.Text[s.Pos] >= '0' && s.Text[s.Pos] <= '9' {
+		s.Pos++
+	}
+	return s.Text[start:s.Pos]
+}
+
+// End checks that only white space is left.
+func (s *Scanner) End() error {
+	s.SkipSpace()
+	if s.Pos < len(s.Text) {
+		return s.Errorf("unexpected %q", s.Text[s.Pos:])
+	}
+	return nil
+}
+
+// Errorf returns an *Error wrapping the syntax error at the current position.
+func (s *Scanner) Errorf(format string, args ...interface{}) error {
+	return s.ErrorAt(s.syntax, s.Pos, format, args...)
+}
+
+// ErrorAt returns an *Error wrapping err at the given position.
+func (s *Scanner) ErrorAt(err error, pos int, format string, args ...interface{}) error {
+	return &Error{Err: err, Offset: pos, Msg: fmt.Sprintf(format, args...)}
+}
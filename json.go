@@ -0,0 +1,60 @@
+package itc
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/fgrid/itc/event"
+	"github.com/fgrid/itc/id"
+)
+
+// JSONReadable selects the form created by Stamp.MarshalJSON: the base64 string of MarshalBinary if
+// false (the default) or nested objects of the ID and event trees, e.g.
+// {"id":{"left":1,"right":0},"event":{"value":0,"left":1,"right":0}}, if true. UnmarshalJSON accepts
+// both forms regardless. JSONReadable is meant to be set once during initialization.
+var JSONReadable = false
+
+// jsonStamp is the readable JSON form of a stamp.
+type jsonStamp struct {
+	ID    id.Readable    `json:"id"`
+	Event event.Readable `json:"event"`
+}
+
+// MarshalJSON encodes the stamp s in the form selected by JSONReadable.
+func (s *Stamp) MarshalJSON() ([]byte, error) {
+	if JSONReadable {
+		return json.Marshal(jsonStamp{ID: id.Readable{ID: s.id}, Event: event.Readable{Event: s.event}})
+	}
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON decodes the stamp s from either form created by MarshalJSON. The decoded stamp is
+// validated like one decoded by UnmarshalBinary and s is left unchanged on errors.
+func (s *Stamp) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var binary []byte
+		if err := json.Unmarshal(data, &binary); err != nil {
+			return err
+		}
+		return s.UnmarshalBinary(binary)
+	}
+	var decoded jsonStamp
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.ID.ID == nil {
+		return id.ErrMalformedID
+	}
+	if decoded.Event.Event == nil {
+		return event.ErrMalformedEvent
+	}
+	stamp := &Stamp{event: decoded.Event.Event, id: decoded.ID.ID}
+	if err := stamp.Validate(); err != nil {
+		return err
+	}
+	*s = *stamp
+	return nil
+}
@@ -0,0 +1,69 @@
+package itc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fgrid/itc/bit"
+	"github.com/fgrid/itc/event"
+	"github.com/fgrid/itc/id"
+	"testing"
+)
+
+func ExampleStamp_MarshalJSON() {
+	a := NewStamp()
+	a.Fork()
+	a.Event()
+	compact, _ := json.Marshal(a)
+	JSONReadable = true
+	readable, _ := json.Marshal(a)
+	JSONReadable = false
+	fmt.Printf("%s\n%s\n", compact, readable)
+	// Output:
+	// "iZA="
+	// {"id":{"left":1,"right":0},"event":{"value":0,"left":1,"right":0}}
+}
+
+func TestStampJSONRoundTrip(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	c := b.Fork()
+	a.Event()
+	c.Event()
+	c.Event()
+	b.Join(a)
+	defer func() { JSONReadable = false }()
+	for _, readable := range []bool{false, true} {
+		JSONReadable = readable
+		for _, s := range []*Stamp{a, b, c, NewStamp()} {
+			data, err := json.Marshal(s)
+			decoded := NewStamp()
+			if err != nil || json.Unmarshal(data, decoded) != nil || !decoded.Equal(s) {
+				t.Errorf("json.Unmarshal(%s) = %s - expected %s (%v)", data, decoded, s, err)
+			}
+		}
+	}
+}
+
+func TestStampUnmarshalJSONInvalid(t *testing.T) {
+	invalid := []struct {
+		data string
+		err  error
+	}{
+		{`{"event":0}`, id.ErrMalformedID},
+		{`{"id":1}`, event.ErrMalformedEvent},
+		{`{"id":{"left":1,"right":1},"event":0}`, id.ErrNotNormalized},
+		{`{"id":1,"event":{"value":0,"left":1}}`, event.ErrMalformedEvent},
+		{`{"id":2,"event":0}`, id.ErrMalformedID},
+		{`"AA=="`, bit.ErrTruncated},
+	}
+	for _, test := range invalid {
+		s := NewStamp()
+		if err := json.Unmarshal([]byte(test.data), s); !errors.Is(err, test.err) {
+			t.Errorf("json.Unmarshal(%s) returned %v - expected %v", test.data, err, test.err)
+		}
+		if s.String() != "(1, 0)" {
+			t.Errorf("failed json.Unmarshal(%s) modified the stamp to %s", test.data, s)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package itc
+
+import (
+	"errors"
+	"github.com/fgrid/itc/event"
+	"github.com/fgrid/itc/id"
+	"github.com/fgrid/itc/internal/scan"
+)
+
+// ErrSyntax is returned when a string does not follow the notation of String.
+var ErrSyntax = errors.New("itc: invalid syntax")
+
+// Parse returns the stamp written in the notation of String, e.g. "(((0, 1), 1), (1, 0, 1))". The
+// parsed stamp is validated like one decoded by UnmarshalBinary. The offsets of syntax errors refer to
+// the whole text, also for errors within the ID or the event component.
+func Parse(text string) (*Stamp, error) {
+	sc := scan.New(text, ErrSyntax)
+	if err := sc.Expect('('); err != nil {
+		return nil, err
+	}
+	start := sc.Pos
+	comma := topLevel(text[start:], ',')
+	if comma < 0 {
+		sc.Pos = len(text)
+		return nil, sc.Errorf("expected ',' between id and event")
+	}
+	i, err := id.Parse(text[start : start+comma])
+	if err != nil {
+		return nil, scan.Shift(err, start)
+	}
+	start += comma + 1
+	end := topLevel(text[start:], ')')
+	if end < 0 {
+		sc.Pos = len(text)
+		return nil, sc.Errorf("expected ')'")
+	}
+	e, err := event.Parse(text[start : start+end])
+	if err != nil {
+		return nil, scan.Shift(err, start)
+	}
+	sc.Pos = start + end + 1
+	if err = sc.End(); err != nil {
+		return nil, err
+	}
+	s := &Stamp{event: e, id: i}
+	if err = s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// topLevel returns the index of the first byte c in text that is not nested in parentheses, or -1 if
+// there is none before a parenthesis closing beyond text.
+func topLevel(text string, c byte) int {
+	depth := 0
+	for n := 0; n < len(text); n++ {
+		switch {
+		case text[n] == c && depth == 0:
+			return n
+		case text[n] == '(':
+			depth++
+		case text[n] == ')':
+			depth--
+			if depth < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// MarshalText encodes the stamp s in the notation of String.
+func (s *Stamp) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the stamp s from the notation of String (see Parse). The stamp s is left
+// unchanged if the text cannot be parsed.
+func (s *Stamp) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*s = *parsed
+	return nil
+}
@@ -0,0 +1,79 @@
+package itc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fgrid/itc/event"
+	"github.com/fgrid/itc/id"
+	"testing"
+)
+
+func ExampleParse() {
+	s, err := Parse("(((0, 1), 1), (1, 0, 1))")
+	fmt.Printf("%s %v\n", s, err)
+	s.Event()
+	fmt.Printf("%s\n", s)
+	// Output:
+	// (((0, 1), 1), (1, 0, 1)) <nil>
+	// (((0, 1), 1), (1, 0, 2))
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	a.Event()
+	c := b.Fork()
+	c.Event()
+	c.Event()
+	a.Join(b)
+	for _, s := range []*Stamp{a, c, NewStamp(), c.Peek()} {
+		text, _ := s.MarshalText()
+		parsed := NewStamp()
+		if err := parsed.UnmarshalText(text); err != nil || !parsed.Equal(s) || parsed.String() != s.String() {
+			t.Errorf("UnmarshalText(%q) = %s, %v", text, parsed, err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []struct {
+		text string
+		err  error
+	}{
+		{"", ErrSyntax},
+		{"(1 0)", ErrSyntax},
+		{"1, 0", ErrSyntax},
+		{"((1, 0) 0)", ErrSyntax},
+		{"(1, (0, 1))", event.ErrSyntax},
+		{"(x, 0)", id.ErrSyntax},
+		{"(2, 0)", id.ErrMalformedID},
+		{"((1, 1), 0)", id.ErrNotNormalized},
+		{"(1, (0, 1, 1))", event.ErrNotNormalized},
+	}
+	for _, test := range invalid {
+		s := NewStamp()
+		if err := s.UnmarshalText([]byte(test.text)); !errors.Is(err, test.err) {
+			t.Errorf("UnmarshalText(%q) returned %v - expected %v", test.text, err, test.err)
+		}
+		if s.String() != "(1, 0)" {
+			t.Errorf("failed UnmarshalText(%q) modified the stamp to %s", test.text, s)
+		}
+	}
+}
+
+func TestParseErrorOffsets(t *testing.T) {
+	tests := []struct {
+		text, err string
+	}{
+		{"(x, 0)", `id: invalid syntax at offset 1: expected 0, 1 or '('`},
+		{"(1, (0, 1))", `event: invalid syntax at offset 9: expected ','`},
+		{"((0, 1), (1, 0, 1)) 1", `itc: invalid syntax at offset 20: unexpected "1"`},
+		{"[1, 0)", `itc: invalid syntax at offset 0: expected '('`},
+		{"((1, 2), 0)", `id: malformed id at offset 5: leaf 2 is neither 0 nor 1`},
+	}
+	for _, test := range tests {
+		if _, err := Parse(test.text); err == nil || err.Error() != test.err {
+			t.Errorf("Parse(%q) returned %v - expected %s", test.text, err, test.err)
+		}
+	}
+}
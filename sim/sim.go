@@ -0,0 +1,241 @@
+// Package sim runs deterministic random traces of Fork, Event, Join, Send and Retire across a
+// changing population of replicas and checks the ordering of every pair of stamps against the ground
+// truth of explicit event sets. Diverging traces are shrunk to a minimal reproducer.
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fgrid/itc"
+	"math/rand"
+)
+
+// Kind is the operation of a step.
+type Kind int
+
+const (
+	// Fork splits replica A into A and a new replica appended to the population.
+	Fork Kind = iota
+	// Event records an event at replica A.
+	Event
+	// Join merges replica B into replica A, B leaves the population.
+	Join
+	// Send records an event at replica A and lets replica B receive its message.
+	Send
+	// Retire hands back the identity of replica A, which leaves the population, to replica B.
+	Retire
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Fork:
+		return "fork"
+	case Event:
+		return "event"
+	case Join:
+		return "join"
+	case Send:
+		return "send"
+	case Retire:
+		return "retire"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Op is a step of a trace acting on the replicas at the indices A and B of the population. B is
+// ignored by Fork and Event.
+type Op struct {
+	Kind Kind
+	A, B int
+}
+
+func (o Op) String() string {
+	if o.Kind == Fork || o.Kind == Event {
+		return fmt.Sprintf("%s %d", o.Kind, o.A)
+	}
+	return fmt.Sprintf("%s %d %d", o.Kind, o.A, o.B)
+}
+
+// Trace is a sequence of steps starting with a population of a single seed stamp.
+type Trace []Op
+
+func (t Trace) String() string {
+	var buf bytes.Buffer
+	for _, op := range t {
+		buf.WriteString(op.String())
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// Generate returns a random trace of the given number of steps that never grows the population
+// beyond the given number of replicas. The same seed always yields the same trace.
+func Generate(seed int64, steps, replicas int) Trace {
+	r := rand.New(rand.NewSource(seed))
+	trace := make(Trace, 0, steps)
+	population := 1
+	for len(trace) < steps {
+		op := Op{Kind: Kind(r.Intn(5)), A: r.Intn(population), B: r.Intn(population)}
+		switch op.Kind {
+		case Fork:
+			if population == replicas {
+				continue
+			}
+			population++
+		case Join, Retire:
+			if op.A == op.B {
+				continue
+			}
+			population--
+		}
+		trace = append(trace, op)
+	}
+	return trace
+}
+
+// Divergence describes the first step of a trace after which a stamp disagrees with the ground truth.
+type Divergence struct {
+	// Trace holds the steps up to and including the diverging one.
+	Trace  Trace
+	Reason string
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("sim: %s after step %d of\n%s", d.Reason, len(d.Trace), d.Trace)
+}
+
+// replica is a stamp along with the set of events it has seen.
+type replica struct {
+	stamp  *itc.Stamp
+	events map[int]bool
+}
+
+func (r *replica) copy(stamp *itc.Stamp) *replica {
+	events := make(map[int]bool, len(r.events))
+	for e := range r.events {
+		events[e] = true
+	}
+	return &replica{stamp: stamp, events: events}
+}
+
+func (r *replica) merge(other *replica) {
+	for e := range other.events {
+		r.events[e] = true
+	}
+}
+
+// Run applies the trace to a single seed stamp and returns a *Divergence as soon as an operation
+// fails, a stamp is invalid or the ordering of two stamps differs from the ordering of their event
+// sets. Steps referring to replicas that do not exist (any more) are skipped, so that any subsequence
+// of a trace can be run.
+func Run(trace Trace) error {
+	population := []*replica{{stamp: itc.NewStamp(), events: map[int]bool{}}}
+	nextEvent := 0
+	event := func(r *replica) {
+		r.events[nextEvent] = true
+		nextEvent++
+	}
+	for step, op := range trace {
+		diverged := func(format string, args ...interface{}) error {
+			return &Divergence{Trace: trace[:step+1], Reason: fmt.Sprintf(format, args...)}
+		}
+		if op.A < 0 || op.A >= len(population) {
+			continue
+		}
+		a := population[op.A]
+		if op.Kind != Fork && op.Kind != Event && (op.B < 0 || op.B >= len(population) || op.B == op.A) {
+			continue
+		}
+		var err error
+		switch op.Kind {
+		case Fork:
+			population = append(population, a.copy(a.stamp.Fork()))
+		case Event:
+			err = a.stamp.Event()
+			event(a)
+		case Join:
+			b := population[op.B]
+			err = a.stamp.Join(b.stamp)
+			a.merge(b)
+			population = append(population[:op.B], population[op.B+1:]...)
+		case Send:
+			b := population[op.B]
+			var msg *itc.Stamp
+			if msg, err = a.stamp.Send(); err == nil {
+				err = b.stamp.Receive(msg)
+			}
+			event(a)
+			b.merge(a)
+			event(b)
+		case Retire:
+			b := population[op.B]
+			err = b.stamp.Absorb(a.stamp.Retire())
+			b.merge(a)
+			population = append(population[:op.A], population[op.A+1:]...)
+		}
+		if err != nil {
+			return diverged("%s failed: %v", op, err)
+		}
+		for i, r := range population {
+			if err := r.stamp.Validate(); err != nil {
+				return diverged("replica %d %s is invalid: %v", i, r.stamp, err)
+			}
+			for j := i + 1; j < len(population); j++ {
+				got, want := r.stamp.Compare(population[j].stamp), ordering(r.events, population[j].events)
+				if got != want {
+					return diverged("replicas %d %s and %d %s are %s instead of %s",
+						i, r.stamp, j, population[j].stamp, got, want)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ordering relates the event sets e1 and e2 like Stamp.Compare relates stamps.
+func ordering(e1, e2 map[int]bool) itc.Ordering {
+	leq, geq := subset(e1, e2), subset(e2, e1)
+	switch {
+	case leq && geq:
+		return itc.Equal
+	case leq:
+		return itc.Before
+	case geq:
+		return itc.After
+	}
+	return itc.Concurrent
+}
+
+func subset(e1, e2 map[int]bool) bool {
+	for e := range e1 {
+		if !e2[e] {
+			return false
+		}
+	}
+	return true
+}
+
+// Shrink returns a subsequence of the trace for which fails still returns 'true', from which no single
+// step can be removed without fails returning 'false'.
+func Shrink(trace Trace, fails func(Trace) bool) Trace {
+	for removed := true; removed; {
+		removed = false
+		for i := len(trace) - 1; i >= 0; i-- {
+			candidate := append(append(Trace{}, trace[:i]...), trace[i+1:]...)
+			if fails(candidate) {
+				trace, removed = candidate, true
+			}
+		}
+	}
+	return trace
+}
+
+// Check runs a random trace generated from the seed and returns nil if it does not diverge. Otherwise
+// the *Divergence of the shrunk trace is returned as a minimal reproducer.
+func Check(seed int64, steps, replicas int) error {
+	trace := Generate(seed, steps, replicas)
+	if Run(trace) == nil {
+		return nil
+	}
+	return Run(Shrink(trace, func(t Trace) bool { return Run(t) != nil }))
+}
@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleGenerate() {
+	fmt.Print(Generate(1, 6, 4))
+	// Output:
+	// event 0
+	// fork 0
+	// fork 0
+	// join 2 1
+	// join 0 1
+	// event 0
+}
+
+func TestCheck(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		if err := Check(seed, 200, 8); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
+
+func TestGenerateLimitsPopulation(t *testing.T) {
+	population := 1
+	for _, op := range Generate(1, 1000, 5) {
+		if op.A >= population || op.B >= population {
+			t.Fatalf("%s refers to a replica beyond the population of %d", op, population)
+		}
+		switch op.Kind {
+		case Fork:
+			population++
+		case Join, Retire:
+			population--
+		}
+		if population < 1 || population > 5 {
+			t.Fatalf("%s leads to a population of %d", op, population)
+		}
+	}
+}
+
+func TestShrink(t *testing.T) {
+	trace := Generate(1, 100, 8)
+	// fails as soon as replica 1 has been forked twice and has recorded an event
+	fails := func(t Trace) bool {
+		forks, events := 0, 0
+		for _, op := range t {
+			if op.Kind == Fork && op.A == 1 {
+				forks++
+			}
+			if op.Kind == Event && op.A == 1 {
+				events++
+			}
+		}
+		return forks >= 2 && events >= 1
+	}
+	if !fails(trace) {
+		t.Fatalf("trace does not fail:\n%s", trace)
+	}
+	if shrunk := Shrink(trace, fails); len(shrunk) != 3 {
+		t.Errorf("Shrink() = \n%s - expected three steps", shrunk)
+	}
+}
+
+func TestRunSkipsMissingReplicas(t *testing.T) {
+	trace := Trace{{Kind: Event, A: 3}, {Kind: Join, A: 0, B: 0}, {Kind: Fork, A: 0}, {Kind: Send, A: 1, B: 0}, {Kind: Retire, A: 0, B: 2}}
+	if err := Run(trace); err != nil {
+		t.Error(err)
+	}
+}
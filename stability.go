@@ -0,0 +1,58 @@
+package itc
+
+import (
+	"github.com/fgrid/itc/event"
+	"github.com/fgrid/itc/id"
+)
+
+// StabilityTracker keeps the latest known event component of every replica of a system and derives
+// the causally stable frontier from them: the events every replica has seen. Data that only depends
+// on stable events, such as tombstones or buffered operations, can be garbage-collected.
+type StabilityTracker struct {
+	events   map[string]*event.Event
+	frontier *event.Event
+}
+
+func NewStabilityTracker() *StabilityTracker {
+	return &StabilityTracker{events: map[string]*event.Event{}}
+}
+
+// Update records the stamp s as known state of the given replica. Stamps arriving out of order do
+// not lose events, as the event components of all stamps of a replica are joined.
+func (t *StabilityTracker) Update(replica string, s *Stamp) {
+	if known, ok := t.events[replica]; ok {
+		t.events[replica] = event.Join(known, s.event)
+	} else {
+		t.events[replica] = s.event
+	}
+	t.frontier = nil
+}
+
+// Remove stops tracking the given replica, e.g. after it has retired.
+func (t *StabilityTracker) Remove(replica string) {
+	delete(t.events, replica)
+	t.frontier = nil
+}
+
+// Frontier returns an anonymous stamp with the events seen by all tracked replicas, which is the
+// meet of their event components. The frontier of no replicas is (0, 0).
+func (t *StabilityTracker) Frontier() *Stamp {
+	if t.frontier == nil {
+		for _, e := range t.events {
+			if t.frontier == nil {
+				t.frontier = e
+			} else {
+				t.frontier = event.Meet(t.frontier, e)
+			}
+		}
+		if t.frontier == nil {
+			t.frontier = event.New()
+		}
+	}
+	return &Stamp{event: t.frontier, id: id.NewWithValue(0)}
+}
+
+// IsStable returns 'true' if all events seen by the stamp s have been seen by all tracked replicas.
+func (t *StabilityTracker) IsStable(s *Stamp) bool {
+	return s.LEQ(t.Frontier())
+}
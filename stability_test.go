@@ -0,0 +1,50 @@
+package itc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleStabilityTracker() {
+	a := NewStamp()
+	b := a.Fork()
+	a.Event()
+	tombstone := a.Peek()
+	tracker := NewStabilityTracker()
+	tracker.Update("a", a)
+	tracker.Update("b", b)
+	fmt.Printf("frontier: %s, stable: %v\n", tracker.Frontier(), tracker.IsStable(tombstone))
+	b.Receive(a.Peek())
+	tracker.Update("b", b)
+	fmt.Printf("frontier: %s, stable: %v\n", tracker.Frontier(), tracker.IsStable(tombstone))
+	// Output:
+	// frontier: (0, 0), stable: false
+	// frontier: (0, (0, 1, 0)), stable: true
+}
+
+func TestStabilityTrackerUpdateAndRemove(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	c := b.Fork()
+	old := a.Peek()
+	a.Event()
+	a.Event()
+	c.Event()
+	tracker := NewStabilityTracker()
+	if frontier := tracker.Frontier(); frontier.String() != "(0, 0)" {
+		t.Errorf("Frontier() without replicas = %s - expected (0, 0)", frontier)
+	}
+	tracker.Update("a", a)
+	tracker.Update("a", old)
+	tracker.Update("c", c)
+	if frontier := tracker.Frontier(); frontier.String() != "(0, 0)" {
+		t.Errorf("Frontier() = %s - expected (0, 0)", frontier)
+	}
+	if !tracker.IsStable(NewStamp().Peek()) || tracker.IsStable(c) {
+		t.Errorf("IsStable() does not follow Frontier() = %s", tracker.Frontier())
+	}
+	tracker.Remove("c")
+	if frontier := tracker.Frontier(); frontier.Compare(a) != Equal || !frontier.IsAnonymous() {
+		t.Errorf("Frontier() after Remove() = %s - expected the events of %s", frontier, a)
+	}
+}
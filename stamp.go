@@ -5,13 +5,27 @@
 // Causality tracking mechanisms can be modeled by a set of core operations: fork; event and join, that
 // act on stamps (logical clocks) whose structure is a pair (i, e), formed by an id and an event component
 // that encodes causally known events.
+//
+// Event, Fork and Join update the stamp they are called on. WithEvent, Forked and Joined return new
+// stamps instead and leave their arguments untouched, so stamps used with them can be shared between
+// goroutines. Stamps share unchanged parts of their ID and event trees, which are never modified in place.
 package itc
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fgrid/itc/bit"
 	"github.com/fgrid/itc/event"
 	"github.com/fgrid/itc/id"
+	"math"
+)
+
+var (
+	// ErrOverlappingIDs is returned when stamps to be joined claim the same part of the identity space.
+	ErrOverlappingIDs = errors.New("itc: stamps with overlapping ids")
+	// ErrAnonymous is returned when an event is to be added to an anonymous stamp, which owns no
+	// part of the identity space to record it in.
+	ErrAnonymous = errors.New("itc: event on anonymous stamp")
 )
 
 // Stamp declares the state of the clock for a given identity and a given stream of events.
@@ -26,15 +40,31 @@ func NewStamp() *Stamp {
 }
 
 // Event adds a new event to the clock's event component, so that if (i, e') results from event((i, e))
-// the causal ordering is such that e < e'.
-func (s *Stamp) Event() {
-	oldE := s.event.Clone()
-	newE := s.fill()
-	if newE.Equals(oldE) {
-		s.event, _ = s.grow()
-	} else {
-		s.event = newE
+// the causal ordering is such that e < e'. event.ErrOverflow is returned and s is left unchanged if the
+// counter to be incremented is at the maximum of uint64, ErrAnonymous if s is anonymous.
+func (s *Stamp) Event() error {
+	next, err := s.WithEvent()
+	if err != nil {
+		return err
+	}
+	s.event = next.event
+	return nil
+}
+
+// WithEvent returns a new stamp with an event added to the event component of stamp s, leaving s
+// unchanged. The new stamp shares the ID and all unchanged parts of the event tree with s.
+func (s *Stamp) WithEvent() (*Stamp, error) {
+	if s.IsAnonymous() {
+		return nil, ErrAnonymous
+	}
+	e := s.fill()
+	if e.Equals(s.event) {
+		var err error
+		if e, err = s.grow(); err != nil {
+			return nil, err
+		}
 	}
+	return &Stamp{event: e, id: s.id}, nil
 }
 
 func (s *Stamp) fill() *event.Event {
@@ -43,23 +73,171 @@ func (s *Stamp) fill() *event.Event {
 
 // Fork clones the causal past of a stamp, resulting in a pair of stamps that
 // have identical copies of the event component and distinct IDs.
+//
+// The ID of a stamp is kept in normal form by all operations and UnmarshalBinary, so splitting it
+// does not fail; Fork panics if that invariant has been broken.
 func (s *Stamp) Fork() *Stamp {
-	st := NewStamp()
-	id1, id2 := s.id.Split()
-	s.id = id1
-	st.id = id2
-	st.event = s.event.Clone()
-	return st
+	a, b := s.Forked()
+	s.id = a.id
+	return b
+}
+
+// Forked returns the pair of stamps Fork would produce, leaving stamp s unchanged. Both stamps share
+// the event component with s.
+func (s *Stamp) Forked() (a, b *Stamp) {
+	id1, id2, err := s.id.Split()
+	if err != nil {
+		panic(fmt.Sprintf("itc: unable to fork stamp %s: %v", s, err))
+	}
+	return &Stamp{event: s.event, id: id1}, &Stamp{event: s.event, id: id2}
+}
+
+// ForkN splits the identity of stamp s into n parts of approximately equal size in one step and
+// returns n stamps with these IDs and the event component of s. The first stamp is s itself, keeping
+// the first part. id.ErrInvalidWeights is returned and s is left unchanged if n is less than 1.
+func (s *Stamp) ForkN(n int) ([]*Stamp, error) {
+	if n < 1 {
+		return nil, id.ErrInvalidWeights
+	}
+	weights := make([]int, n)
+	for k := range weights {
+		weights[k] = 1
+	}
+	return s.ForkWeighted(weights...)
+}
+
+// ForkWeighted splits the identity of stamp s into parts approximately proportional to the given
+// weights (see id.ID.SplitWeighted) and returns one stamp per weight like ForkN. A stamp of weight 0
+// is anonymous. id.ErrInvalidWeights is returned and s is left unchanged if a weight is negative,
+// all weights are 0 or their sum exceeds an eighth of math.MaxInt.
+func (s *Stamp) ForkWeighted(weights ...int) ([]*Stamp, error) {
+	ids, err := s.id.SplitWeighted(weights...)
+	if err != nil {
+		return nil, err
+	}
+	stamps := make([]*Stamp, len(ids))
+	for k, i := range ids {
+		stamps[k] = &Stamp{event: s.event, id: i}
+	}
+	s.id = ids[0]
+	stamps[0] = s
+	return stamps, nil
+}
+
+// Rebalance returns replacements for the stamps, whose IDs have to be disjoint, with shallower IDs
+// owning exactly as much of the identity space as before (see id.Rebalance). Each replacement keeps the
+// events of its stamp and learns the events recorded in the parts of the identity space it takes
+// over, so that the events it records there are new. It gets the smaller of two event trees: its
+// event joined with those of the previous owners either within the parts taken over only or as a
+// whole. If the replacements would be larger than the stamps in total, copies of the stamps are
+// returned instead. Every replica has to adopt its replacement before recording further events.
+// ErrOverlappingIDs is returned if the IDs are not disjoint.
+func Rebalance(stamps ...*Stamp) ([]*Stamp, error) {
+	ids := make([]*id.ID, len(stamps))
+	for k, s := range stamps {
+		ids[k] = s.id
+	}
+	rebalanced, err := id.Rebalance(ids...)
+	if err == id.ErrOverlapping {
+		return nil, ErrOverlappingIDs
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, unchanged := make([]*Stamp, len(stamps)), make([]*Stamp, len(stamps))
+	before, after := 0, 0
+	for k, i := range rebalanced {
+		within, joined := stamps[k].event, stamps[k].event
+		for j, s := range stamps {
+			if j != k && id.Overlaps(s.id, i) {
+				within, joined = raise(s.id, i, within, s.event), event.Join(joined, s.event)
+			}
+		}
+		result[k] = &Stamp{event: within, id: i}
+		if other := (&Stamp{event: joined, id: i}); other.size() < result[k].size() {
+			result[k] = other
+		}
+		unchanged[k] = &Stamp{event: stamps[k].event, id: stamps[k].id}
+		before, after = before+stamps[k].size(), after+result[k].size()
+	}
+	if after > before {
+		return unchanged, nil
+	}
+	return result, nil
+}
+
+// size returns the number of bytes of the binary form of the stamp s without envelope.
+func (s *Stamp) size() int {
+	bp := bit.NewPack()
+	s.Pack(bp)
+	return len(bp.Bytes())
+}
+
+func (s *Stamp) grow() (*event.Event, error) {
+	return grow(s.id, s.event, 0)
+}
+
+// Join merges two stamps, producing a new one. ErrOverlappingIDs is returned and s is left unchanged
+// if the IDs of both stamps are not disjoint, e.g. when joining a stamp with a stale copy of itself.
+func (s *Stamp) Join(other *Stamp) error {
+	joined, err := Joined(s, other)
+	if err != nil {
+		return err
+	}
+	*s = *joined
+	return nil
+}
+
+// Joined returns the stamp Join would produce from the stamps a and b, leaving both unchanged.
+func Joined(a, b *Stamp) (*Stamp, error) {
+	if id.Overlaps(a.id, b.id) {
+		return nil, ErrOverlappingIDs
+	}
+	return &Stamp{event: event.Join(a.event, b.event), id: id.New().Sum(a.id, b.id)}, nil
+}
+
+// Peek returns an anonymous stamp (0, e) carrying only the event component of stamp s. Peeked
+// stamps own no part of the identity space, so joining them never hands out an identity.
+func (s *Stamp) Peek() *Stamp {
+	return &Stamp{event: s.event, id: id.NewWithValue(0)}
+}
+
+// Send adds a new event to the stamp s and returns the anonymous stamp to be attached to an
+// outgoing message, as defined by send = peek . event. The error of the event is returned, if any.
+func (s *Stamp) Send() (*Stamp, error) {
+	if err := s.Event(); err != nil {
+		return nil, err
+	}
+	return s.Peek(), nil
+}
+
+// Receive joins the stamp of an incoming message msg into the stamp s and adds a new event,
+// as defined by receive = event . join. The error of the join or the event is returned, if any.
+func (s *Stamp) Receive(msg *Stamp) error {
+	if err := s.Join(msg); err != nil {
+		return err
+	}
+	return s.Event()
+}
+
+// IsAnonymous returns 'true' if the stamp s owns no part of the identity space (as created by Peek).
+func (s *Stamp) IsAnonymous() bool {
+	return s.id.IsLeaf && s.id.Value == 0
 }
 
-func (s *Stamp) grow() (*event.Event, int) {
-	return grow(s.id, s.event)
+// Retire hands back the identity of the stamp s for a replica leaving the system. The returned stamp
+// carries the ID and event component of s and is to be absorbed by a remaining replica; s itself
+// becomes anonymous.
+func (s *Stamp) Retire() *Stamp {
+	retired := &Stamp{event: s.event, id: s.id}
+	s.id = id.NewWithValue(0)
+	return retired
 }
 
-// Join merges two stamps, producing a new one.
-func (s *Stamp) Join(other *Stamp) {
-	s.id = id.New().Sum(s.id, other.id)
-	s.event = event.Join(s.event, other.event)
+// Absorb joins a stamp handed back by Retire into the stamp s. ErrOverlappingIDs is returned and s
+// is left unchanged if the IDs of both stamps are not disjoint.
+func (s *Stamp) Absorb(retired *Stamp) error {
+	return s.Join(retired)
 }
 
 // LEQ Compares the stamp with the given other stamp and returns 'true' if this stamp is less or equal (LEQ).
@@ -67,11 +245,80 @@ func (s *Stamp) LEQ(other *Stamp) bool {
 	return event.LEQ(s.event, other.event)
 }
 
-// MarshalBinary encodes the stamp s into a binary form and returns the result.
+// Ordering describes the causal relation between two stamps.
+type Ordering int
+
+const (
+	// Equal stamps have seen exactly the same events.
+	Equal Ordering = iota
+	// Before means the stamp happened before the other one.
+	Before
+	// After means the stamp happened after the other one.
+	After
+	// Concurrent stamps have each seen events the other one has not.
+	Concurrent
+)
+
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "Equal"
+	case Before:
+		return "Before"
+	case After:
+		return "After"
+	case Concurrent:
+		return "Concurrent"
+	}
+	return fmt.Sprintf("Ordering(%d)", int(o))
+}
+
+// Compare relates the event components of stamp s and the given other stamp. Both directions
+// are decided in a single walk over the event trees.
+func (s *Stamp) Compare(other *Stamp) Ordering {
+	leq, geq := event.Compare(s.event, other.event)
+	switch {
+	case leq && geq:
+		return Equal
+	case leq:
+		return Before
+	case geq:
+		return After
+	}
+	return Concurrent
+}
+
+// Equal returns 'true' if the stamp s owns the same part of the identity space and has seen exactly the
+// same events as the given other stamp. Stamps of different replicas are never equal; use Compare to
+// relate their events only.
+func (s *Stamp) Equal(other *Stamp) bool {
+	return s.id.Equal(other.id) && s.Compare(other) == Equal
+}
+
+// Clone returns a deep copy of the stamp s.
+func (s *Stamp) Clone() *Stamp {
+	return &Stamp{event: s.event.Clone(), id: s.id.Clone()}
+}
+
+// Concurrent returns 'true' if neither the stamp s nor the given other stamp has seen all events of the other.
+func (s *Stamp) Concurrent(other *Stamp) bool {
+	return s.Compare(other) == Concurrent
+}
+
+// HappenedBefore returns 'true' if the stamp s is strictly less than the given other stamp.
+func (s *Stamp) HappenedBefore(other *Stamp) bool {
+	return s.Compare(other) == Before
+}
+
+// MarshalBinary encodes the stamp s into a binary form and returns the result. The encoding is
+// wrapped in a versioned envelope if BinaryEnvelope is set.
 func (s *Stamp) MarshalBinary() ([]byte, error) {
 	bp := bit.NewPack()
 	s.Pack(bp)
-	return bp.Pack(), nil
+	if BinaryEnvelope {
+		return envelope(bp), nil
+	}
+	return bp.Bytes(), nil
 }
 
 // String returns the string corresponding to stamp s.
@@ -79,16 +326,39 @@ func (s *Stamp) String() string {
 	return fmt.Sprintf("(%s, %s)", s.id, s.event)
 }
 
-// UnmarshalBinary decodes the stamp s from the given binary form data (created by MarshalBinary).
+// UnmarshalBinary decodes the stamp s from the given binary form data (created by MarshalBinary),
+// with or without envelope. The decoded stamp is validated and has to take exactly the length given by
+// its envelope, so that corrupted data is rejected and leaves s unchanged.
 func (s *Stamp) UnmarshalBinary(data []byte) error {
-	bup := bit.NewUnPack(data)
-	s.UnPack(bup)
+	data, bitLength, err := unwrapEnvelope(data)
+	if err != nil {
+		return err
+	}
+	decoded, bup := &Stamp{}, bit.NewUnPack(data)
+	if err := decoded.UnPack(bup); err != nil {
+		return err
+	}
+	if bitLength >= 0 && 8*len(data)-bup.Remaining() != bitLength {
+		return ErrEnvelopeLength
+	}
+	if err := decoded.Validate(); err != nil {
+		return err
+	}
+	*s = *decoded
 	return nil
 }
 
+// Validate checks that both the ID and the event component of the stamp s are well formed and normalized.
+func (s *Stamp) Validate() error {
+	if err := s.id.Validate(); err != nil {
+		return err
+	}
+	return s.event.Validate()
+}
+
 func fill(i *id.ID, e *event.Event) *event.Event {
 	if i.IsLeaf {
-		if i.Value == 0 {
+		if i.Value == 0 || e.IsLeaf {
 			return e
 		}
 		return event.NewLeaf(e.Max())
@@ -96,62 +366,133 @@ func fill(i *id.ID, e *event.Event) *event.Event {
 	if e.IsLeaf {
 		return e
 	}
-	r := event.NewEmptyNode(e.Value)
+	left, right := e.Left, e.Right
 	if i.Left.IsLeaf && i.Left.Value == 1 {
-		r.Right = fill(i.Right, e.Right)
-		r.Left = event.NewLeaf(event.Max(e.Left.Max(), r.Right.Min()))
+		right = fill(i.Right, e.Right)
+		left = maxLeaf(e.Left, right.Min())
 	} else if i.Right.IsLeaf && i.Right.Value == 1 {
-		r.Left = fill(i.Left, e.Left)
-		r.Right = event.NewLeaf(event.Max(e.Right.Max(), r.Left.Min()))
+		left = fill(i.Left, e.Left)
+		right = maxLeaf(e.Right, left.Min())
 	} else {
-		r.Left = fill(i.Left, e.Left)
-		r.Right = fill(i.Right, e.Right)
+		left, right = fill(i.Left, e.Left), fill(i.Right, e.Right)
+	}
+	if left == e.Left && right == e.Right {
+		return e
 	}
-	return r.Norm()
+	return event.Node(e.Value, left, right)
 }
 
-func grow(i *id.ID, e *event.Event) (*event.Event, int) {
+// raise returns the event e joined with the event other within the part of the identity space owned
+// by both IDs a and b. Elsewhere e is kept, so that a stamp taking over a part of the identity space
+// only learns the events recorded there.
+func raise(a, b *id.ID, e, other *event.Event) *event.Event {
+	if a.IsLeaf && a.Value == 0 || b.IsLeaf && b.Value == 0 || event.LEQ(other, e) {
+		return e
+	}
+	if a.IsLeaf && b.IsLeaf {
+		return event.Join(e, other)
+	}
+	aLeft, aRight := a.Halves()
+	bLeft, bRight := b.Halves()
+	eLeft, eRight := lifted(e)
+	otherLeft, otherRight := lifted(other)
+	return event.Node(0, raise(aLeft, bLeft, eLeft, otherLeft), raise(aRight, bRight, eRight, otherRight))
+}
+
+// lifted returns the subtrees of the event e raised by the value of e, a leaf being treated as a node
+// with two leaves of its value.
+func lifted(e *event.Event) (left, right *event.Event) {
+	if e.IsLeaf {
+		return e, e
+	}
+	return lift(e.Left, e.Value), lift(e.Right, e.Value)
+}
+
+// lift returns the event e raised by value, sharing the subtrees of e.
+func lift(e *event.Event, value uint64) *event.Event {
+	return &event.Event{Value: e.Value + value, Left: e.Left, Right: e.Right, IsLeaf: e.IsLeaf}
+}
+
+// maxLeaf returns a leaf with the maximum of event e and value, which is e itself if possible.
+func maxLeaf(e *event.Event, value uint64) *event.Event {
+	m := event.Max(e.Max(), value)
+	if e.IsLeaf && e.Value == m {
+		return e
+	}
+	return event.NewLeaf(m)
+}
+
+// grow inflates the event e at the place within the ID i that is cheapest according to growCost.
+// Only the nodes along the inflated path are allocated. base is the accumulated value of the
+// ancestors of e, so that event.ErrOverflow is returned instead of wrapping the inflated counter.
+func grow(i *id.ID, e *event.Event, base uint64) (*event.Event, error) {
 	if e.IsLeaf {
 		if i.IsLeaf && i.Value == 1 {
-			return event.NewLeaf(e.Value + 1), 0
+			if e.Value == math.MaxUint64-base {
+				return nil, event.ErrOverflow
+			}
+			return event.NewLeaf(e.Value + 1), nil
 		}
-		ex, c := grow(i, event.NewEmptyNode(e.Value))
-		return ex, c + 99999
+		return grow(i, event.NewNode(e.Value, 0, 0), base)
+	}
+	left, right := e.Left, e.Right
+	var err error
+	switch {
+	case i.Left.IsLeaf && i.Left.Value == 0:
+		right, err = grow(i.Right, e.Right, base+e.Value)
+	case i.Right.IsLeaf && i.Right.Value == 0:
+		left, err = grow(i.Left, e.Left, base+e.Value)
+	case growCost(i.Left, e.Left) < growCost(i.Right, e.Right):
+		left, err = grow(i.Left, e.Left, base+e.Value)
+	default:
+		right, err = grow(i.Right, e.Right, base+e.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event.Event{Value: e.Value, Left: left, Right: right}, nil
+}
+
+// growCost returns the cost of growing the event e within the ID i as defined in section "5.3.3 Event".
+// Expanding a leaf into a node is to be avoided and therefore expensive.
+func growCost(i *id.ID, e *event.Event) int {
+	if !e.IsLeaf {
+		return nodeGrowCost(i, e.Left, e.Right)
+	}
+	if i.IsLeaf && i.Value == 1 {
+		return 0
 	}
+	return nodeGrowCost(i, e, e) + 99999
+}
+
+func nodeGrowCost(i *id.ID, left, right *event.Event) int {
 	if i.Left.IsLeaf && i.Left.Value == 0 {
-		exr, cr := grow(i.Right, e.Right)
-		r := event.NewEmptyNode(e.Value)
-		r.Left = e.Left
-		r.Right = exr
-		return r, cr + 1
+		return growCost(i.Right, right) + 1
 	}
 	if i.Right.IsLeaf && i.Right.Value == 0 {
-		exl, cl := grow(i.Left, e.Left)
-		r := event.NewEmptyNode(e.Value)
-		r.Left = exl
-		r.Right = e.Right
-		return r, cl + 1
-	}
-	exl, cl := grow(i.Left, e.Left)
-	exr, cr := grow(i.Right, e.Right)
+		return growCost(i.Left, left) + 1
+	}
+	cl, cr := growCost(i.Left, left), growCost(i.Right, right)
 	if cl < cr {
-		r := event.NewEmptyNode(e.Value)
-		r.Left = exl
-		r.Right = e.Right
-		return r, cl + 1
+		return cl + 1
 	}
-	r := event.NewEmptyNode(e.Value)
-	r.Left = e.Left
-	r.Right = exr
-	return r, cr + 1
+	return cr + 1
 }
 
-func (s *Stamp) Pack(p *bit.Pack) {
+func (s *Stamp) Pack(p bit.Pusher) {
 	s.id.Pack(p)
 	s.event.Pack(p)
 }
 
-func (s *Stamp) UnPack(bup *bit.UnPack) {
-	s.id = id.UnPack(bup)
-	s.event = event.UnPack(bup)
+func (s *Stamp) UnPack(bup bit.Popper) error {
+	i, err := id.UnPack(bup)
+	if err != nil {
+		return err
+	}
+	e, err := event.UnPack(bup)
+	if err != nil {
+		return err
+	}
+	s.id, s.event = i, e
+	return nil
 }
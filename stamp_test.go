@@ -1,7 +1,14 @@
 package itc
 
 import (
+	"errors"
 	"fmt"
+	"github.com/fgrid/itc/bit"
+	"github.com/fgrid/itc/event"
+	"github.com/fgrid/itc/id"
+	"math"
+	"math/big"
+	"math/rand"
 	"testing"
 )
 
@@ -160,7 +167,7 @@ func ExampleStamp_MarshalBinary_seed() {
 	seedData, _ := seed.MarshalBinary()
 	fmt.Printf("%s = % x\n", seed, seedData)
 	// Output:
-	// (1, 0) = 30 00 00 00
+	// (1, 0) = 30
 }
 
 func ExampleStamp_MarshalBinary_seedAfterFork() {
@@ -169,7 +176,7 @@ func ExampleStamp_MarshalBinary_seedAfterFork() {
 	seedData, _ := seed.MarshalBinary()
 	fmt.Printf("%s = % x\n", seed, seedData)
 	// Output:
-	// ((1, 0), 0) = 8c 00 00 00
+	// ((1, 0), 0) = 8c 00
 }
 
 func ExampleStamp_UnmarshalBinary() {
@@ -180,3 +187,592 @@ func ExampleStamp_UnmarshalBinary() {
 	// Output:
 	// 8c 00 00 00 = ((1, 0), 0)
 }
+
+func ExampleStamp_Compare() {
+	a := NewStamp()
+	b := a.Fork()
+	c := b.Fork()
+	fmt.Printf("a.Compare(b) = %s\n", a.Compare(b))
+	a.Event()
+	fmt.Printf("a.Compare(b) = %s\n", a.Compare(b))
+	fmt.Printf("b.Compare(a) = %s\n", b.Compare(a))
+	c.Event()
+	fmt.Printf("a.Compare(c) = %s\n", a.Compare(c))
+	b.Join(a)
+	b.Join(c)
+	fmt.Printf("a.Compare(b) = %s\n", a.Compare(b))
+	// Output:
+	// a.Compare(b) = Equal
+	// a.Compare(b) = After
+	// b.Compare(a) = Before
+	// a.Compare(c) = Concurrent
+	// a.Compare(b) = Before
+}
+
+func TestStampCompareHelpers(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	if a.Compare(b) != Equal || a.Concurrent(b) || a.HappenedBefore(b) {
+		t.Errorf("fresh fork %s should equal %s", b, a)
+	}
+	b.Event()
+	if !a.HappenedBefore(b) || b.HappenedBefore(a) {
+		t.Errorf("%s should have happened before %s", a, b)
+	}
+	a.Event()
+	if !a.Concurrent(b) || !b.Concurrent(a) {
+		t.Errorf("%s and %s should be concurrent", a, b)
+	}
+}
+
+func ExampleStamp_Send() {
+	a := NewStamp()
+	b := a.Fork()
+	msg, _ := a.Send()
+	fmt.Printf("a: %s\n", a)
+	fmt.Printf("msg: %s\n", msg)
+	b.Receive(msg)
+	fmt.Printf("b: %s\n", b)
+	// Output:
+	// a: ((1, 0), (0, 1, 0))
+	// msg: (0, (0, 1, 0))
+	// b: ((0, 1), 1)
+}
+
+func TestStampEventOverflow(t *testing.T) {
+	s := &Stamp{event: event.NewNode(math.MaxUint64-1, 0, 1), id: id.New()}
+	if err := s.Event(); err != nil || s.String() != "(1, 18446744073709551615)" {
+		t.Fatalf("Event() = %v, %s - expected the maximum counter", err, s)
+	}
+	if err := s.Event(); err != event.ErrOverflow || s.String() != "(1, 18446744073709551615)" {
+		t.Errorf("Event() at the maximum counter = %v, %s - expected %v and no change", err, s, event.ErrOverflow)
+	}
+	if _, err := s.Send(); err != event.ErrOverflow {
+		t.Errorf("Send() at the maximum counter = %v - expected %v", err, event.ErrOverflow)
+	}
+}
+
+func TestStampEventAnonymous(t *testing.T) {
+	s := NewStamp()
+	s.Event()
+	peeked := s.Peek()
+	if err := peeked.Event(); err != ErrAnonymous || peeked.String() != "(0, 1)" {
+		t.Errorf("Event() on anonymous stamp = %v, %s - expected %v and no change", err, peeked, ErrAnonymous)
+	}
+}
+
+func TestStampPeekIsAnonymous(t *testing.T) {
+	a := NewStamp()
+	a.Event()
+	p := a.Peek()
+	if !p.IsAnonymous() || a.IsAnonymous() {
+		t.Errorf("peek %s of %s should be the only anonymous stamp", p, a)
+	}
+	if p.Compare(a) != Equal {
+		t.Errorf("peek %s should carry the events of %s", p, a)
+	}
+	a.Event()
+	if !p.HappenedBefore(a) {
+		t.Errorf("peek %s should not follow events of %s", p, a)
+	}
+}
+
+func TestStampUnmarshalBinaryTruncated(t *testing.T) {
+	stamp := NewStamp()
+	for _, data := range [][]byte{nil, {0x8c}} {
+		if err := stamp.UnmarshalBinary(data); !errors.Is(err, bit.ErrTruncated) {
+			t.Errorf("UnmarshalBinary(% x) returned %v - expected %v", data, err, bit.ErrTruncated)
+		}
+	}
+	if stamp.String() != "(1, 0)" {
+		t.Errorf("failed UnmarshalBinary modified the stamp to %s", stamp)
+	}
+}
+
+func TestStampMarshalBinaryRoundTrip(t *testing.T) {
+	stamps := []*Stamp{NewStamp()}
+	for round := 0; round < 5; round++ {
+		for _, s := range stamps {
+			s.Event()
+			stamps = append(stamps, s.Fork())
+		}
+	}
+	for _, s := range stamps {
+		data, _ := s.MarshalBinary()
+		decoded := NewStamp()
+		if err := decoded.UnmarshalBinary(data); err != nil || decoded.String() != s.String() {
+			t.Errorf("UnmarshalBinary(MarshalBinary(%s)) = %s, %v", s, decoded, err)
+		}
+	}
+}
+
+func TestStampUnmarshalBinaryNotNormalized(t *testing.T) {
+	bp := bit.NewPack()
+	(&id.ID{Left: id.NewWithValue(0), Right: id.NewWithValue(0)}).Pack(bp)
+	event.New().Pack(bp)
+	stamp := NewStamp()
+	if err := stamp.UnmarshalBinary(bp.Pack()); err != id.ErrNotNormalized {
+		t.Errorf("UnmarshalBinary(%s) returned %v - expected %v", bp, err, id.ErrNotNormalized)
+	}
+
+	bp = bit.NewPack()
+	id.New().Pack(bp)
+	event.NewNode(0, 1, 1).Pack(bp)
+	if err := stamp.UnmarshalBinary(bp.Pack()); err != event.ErrNotNormalized {
+		t.Errorf("UnmarshalBinary(%s) returned %v - expected %v", bp, err, event.ErrNotNormalized)
+	}
+	if stamp.String() != "(1, 0)" {
+		t.Errorf("failed UnmarshalBinary modified the stamp to %s", stamp)
+	}
+}
+
+func ExampleJoined() {
+	seed := NewStamp()
+	a, b := seed.Forked()
+	a, _ = a.WithEvent()
+	b, _ = b.WithEvent()
+	b, _ = b.WithEvent()
+	fmt.Printf("seed: %s\n", seed)
+	fmt.Printf("a: %s\n", a)
+	fmt.Printf("b: %s\n", b)
+	joined, _ := Joined(a, b)
+	fmt.Printf("joined: %s\n", joined)
+	// Output:
+	// seed: (1, 0)
+	// a: ((1, 0), (0, 1, 0))
+	// b: ((0, 1), (0, 0, 2))
+	// joined: (1, (1, 0, 1))
+}
+
+func TestStampImmutableOperations(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	a.Event()
+	b.Event()
+	c := a.Fork()
+	aString, bString, cString := a.String(), b.String(), c.String()
+
+	a.WithEvent()
+	a.Forked()
+	Joined(b, c)
+	if a.String() != aString || b.String() != bString || c.String() != cString {
+		t.Errorf("stamps changed from %s, %s, %s to %s, %s, %s", aString, bString, cString, a, b, c)
+	}
+}
+
+// deepStamp returns one of 2^depth stamps forked from a seed, all of which recorded an event.
+func deepStamp(depth int) *Stamp {
+	stamps := []*Stamp{NewStamp()}
+	for level := 0; level < depth; level++ {
+		for _, s := range stamps {
+			stamps = append(stamps, s.Fork())
+		}
+	}
+	joined := NewStamp()
+	for _, s := range stamps {
+		s.Event()
+		joined, _ = Joined(joined, s.Peek())
+	}
+	stamps[0].event = joined.event
+	return stamps[0]
+}
+
+func BenchmarkStampEventDeep(b *testing.B) {
+	s := deepStamp(10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s.WithEvent()
+	}
+}
+
+// largeStamps returns the joined event components of all but the last and of all of the given
+// number of replicas, each of which recorded a random number of events.
+func largeStamps(replicas int, r *rand.Rand) (some, all *Stamp) {
+	stamps := []*Stamp{NewStamp()}
+	for len(stamps) < replicas {
+		stamps = append(stamps, stamps[r.Intn(len(stamps))].Fork())
+	}
+	all = NewStamp().Peek()
+	for n, s := range stamps {
+		for events := r.Intn(4); events > 0; events-- {
+			s.Event()
+		}
+		if n == len(stamps)-1 {
+			some = all
+		}
+		all, _ = Joined(all, s.Peek())
+	}
+	return some, all
+}
+
+func BenchmarkStampLEQLarge(b *testing.B) {
+	some, all := largeStamps(4096, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !some.LEQ(all) {
+			b.Fatalf("%s is not LEQ %s", some, all)
+		}
+	}
+}
+
+func BenchmarkStampCompareLarge(b *testing.B) {
+	some, all := largeStamps(4096, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		some.Compare(all)
+	}
+}
+
+func ExampleStamp_Retire() {
+	a := NewStamp()
+	b := a.Fork()
+	c := b.Fork()
+	c.Event()
+	retired := c.Retire()
+	fmt.Printf("retired: %s\n", retired)
+	fmt.Printf("c: %s\n", c)
+	fmt.Printf("absorb: %v\n", b.Absorb(retired))
+	fmt.Printf("b: %s\n", b)
+	// Output:
+	// retired: ((0, (0, 1)), (0, 0, (0, 0, 1)))
+	// c: (0, (0, 0, (0, 0, 1)))
+	// absorb: <nil>
+	// b: ((0, 1), (0, 0, (0, 0, 1)))
+}
+
+func TestStampAbsorbOverlapping(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	c := b.Fork()
+	retired := c.Retire()
+	if err := b.Absorb(retired); err != nil {
+		t.Errorf("absorbing disjoint %s into %s failed: %v", retired, b, err)
+	}
+	before := b.String()
+	if err := b.Absorb(retired); err != ErrOverlappingIDs {
+		t.Errorf("absorbing %s again into %s returned %v - expected %v", retired, b, err, ErrOverlappingIDs)
+	}
+	if b.String() != before {
+		t.Errorf("failed absorb modified %s to %s", before, b)
+	}
+}
+
+func TestStampJoinOverlapping(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	stale := b.Peek()
+	stale.id = b.id
+	b.Event()
+	before := b.String()
+	if err := b.Join(stale); err != ErrOverlappingIDs {
+		t.Errorf("joining %s with stale copy %s returned %v - expected %v", b, stale, err, ErrOverlappingIDs)
+	}
+	if err := b.Join(b); err != ErrOverlappingIDs {
+		t.Errorf("joining %s with itself returned %v - expected %v", b, err, ErrOverlappingIDs)
+	}
+	if b.String() != before {
+		t.Errorf("failed join modified %s to %s", before, b)
+	}
+	if err := b.Receive(a.Peek()); err != nil {
+		t.Errorf("receiving anonymous %s failed: %v", a.Peek(), err)
+	}
+}
+
+func TestStampCloneAndEqual(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	a.Event()
+	c := a.Clone()
+	if !c.Equal(a) || !a.Equal(c) {
+		t.Errorf("clone %s should equal %s", c, a)
+	}
+	if c.id == a.id || c.event == a.event {
+		t.Errorf("clone %s shares its trees with %s", c, a)
+	}
+	if b.Equal(a.Peek()) || a.Peek().Equal(b) {
+		t.Errorf("%s should differ from %s", b, a.Peek())
+	}
+	b.Event()
+	if a.Equal(b) {
+		t.Errorf("%s should differ from %s", a, b)
+	}
+	c.id = &id.ID{Left: id.New(), Right: id.NewWithValue(0)}
+	c.event = &event.Event{Value: 0, Left: event.NewLeaf(1), Right: &event.Event{Value: 0, Left: event.New(), Right: event.New()}}
+	if !c.Equal(a) {
+		t.Errorf("not normalized %s should equal %s", c, a)
+	}
+}
+
+func TestStampLaws(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	stamps := []*Stamp{NewStamp()}
+	for step := 0; step < 2000; step++ {
+		k := r.Intn(len(stamps))
+		s := stamps[k]
+		switch op := r.Intn(4); {
+		case op == 0 && len(stamps) < 32:
+			a, b := s.Forked()
+			if a.Compare(s) != Equal || b.Compare(s) != Equal {
+				t.Fatalf("fork(%s) = %s, %s changed the events", s, a, b)
+			}
+			if id.Overlaps(a.id, b.id) || !id.New().Sum(a.id, b.id).Equal(s.id) {
+				t.Fatalf("fork(%s) = %s, %s does not partition the id", s, a, b)
+			}
+			stamps[k] = a
+			stamps = append(stamps, b)
+		case op == 1 && len(stamps) > 1:
+			other := (k + 1 + r.Intn(len(stamps)-1)) % len(stamps)
+			joined, err := Joined(s, stamps[other])
+			if err != nil || !s.LEQ(joined) || !stamps[other].LEQ(joined) {
+				t.Fatalf("join(%s, %s) = %s, %v is no upper bound", s, stamps[other], joined, err)
+			}
+			stamps[k] = joined
+			stamps = append(stamps[:other], stamps[other+1:]...)
+			if other < k {
+				k--
+			}
+		default:
+			next, err := s.WithEvent()
+			if err != nil || s.Compare(next) != Before {
+				t.Fatalf("event(%s) = %s, %v did not increase", s, next, err)
+			}
+			stamps[k] = next
+		}
+		data, _ := stamps[k].MarshalBinary()
+		decoded := &Stamp{}
+		if err := decoded.UnmarshalBinary(data); err != nil || !decoded.Equal(stamps[k]) {
+			t.Fatalf("unmarshal(marshal(%s)) = %s, %v", stamps[k], decoded, err)
+		}
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	a := NewStamp()
+	b := a.Fork()
+	a.Event()
+	b.Event()
+	for _, s := range []*Stamp{NewStamp(), a, b, a.Peek()} {
+		data, _ := s.MarshalBinary()
+		f.Add(data)
+	}
+	f.Add([]byte{0x45, 0x01, 0x07, 0x30})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := &Stamp{}
+		if err := s.UnmarshalBinary(data); err != nil {
+			return
+		}
+		encoded, _ := s.MarshalBinary()
+		decoded := &Stamp{}
+		if err := decoded.UnmarshalBinary(encoded); err != nil || !decoded.Equal(s) {
+			t.Errorf("unmarshal(marshal(%s)) = %s, %v", s, decoded, err)
+		}
+		if err := s.Event(); err != nil && err != event.ErrOverflow && err != ErrAnonymous {
+			t.Errorf("event(%s) = %v", s, err)
+		}
+	})
+}
+
+func ExampleStamp_ForkN() {
+	seed := NewStamp()
+	seed.Event()
+	stamps, _ := seed.ForkN(3)
+	for _, s := range stamps {
+		fmt.Println(s)
+	}
+	// Output:
+	// (((1, ((1, 0), 0)), 0), 1)
+	// (((0, ((0, 1), 1)), ((1, 0), 0)), 1)
+	// ((0, ((0, 1), 1)), 1)
+}
+
+func ExampleStamp_ForkWeighted() {
+	stamps, _ := NewStamp().ForkWeighted(2, 1, 0, 1)
+	for _, s := range stamps {
+		fmt.Println(s)
+	}
+	// Output:
+	// ((1, 0), 0)
+	// ((0, (1, 0)), 0)
+	// (0, 0)
+	// ((0, (0, 1)), 0)
+}
+
+func TestStampForkWeighted(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	stamps := []*Stamp{NewStamp()}
+	for len(stamps) < 8 {
+		stamps = append(stamps, stamps[r.Intn(len(stamps))].Fork())
+	}
+	for n := 0; n < 200; n++ {
+		s := stamps[r.Intn(len(stamps))].Clone()
+		original := s.Clone()
+		weights := make([]int, 1+r.Intn(20))
+		total := 0
+		for k := range weights {
+			weights[k] = r.Intn(5)
+			total += weights[k]
+		}
+		if total == 0 {
+			continue
+		}
+		parts, err := s.ForkWeighted(weights...)
+		if err != nil || parts[0] != s || len(parts) != len(weights) {
+			t.Fatalf("ForkWeighted(%v) of %s returned %d stamps not starting with s", weights, original, len(parts))
+		}
+		unit := new(big.Rat).Quo(original.id.Fraction(), big.NewRat(int64(total), 1))
+		sum := id.NewWithValue(0)
+		for k, part := range parts {
+			if id.Overlaps(sum, part.id) || part.Compare(original) != Equal || part.Validate() != nil {
+				t.Fatalf("ForkWeighted(%v) of %s returned overlapping or invalid %s", weights, original, part)
+			}
+			if (weights[k] == 0) != part.IsAnonymous() {
+				t.Fatalf("ForkWeighted(%v) of %s returned %s for weight %d", weights, original, part, weights[k])
+			}
+			exact := new(big.Rat).Mul(unit, big.NewRat(int64(weights[k]), 1))
+			deviation := new(big.Rat).Sub(part.id.Fraction(), exact)
+			if deviation.Abs(deviation).Mul(deviation, big.NewRat(4, 1)).Cmp(unit) >= 0 {
+				t.Fatalf("ForkWeighted(%v) of %s returned %s owning %s instead of %s", weights, original, part,
+					part.id.Fraction().RatString(), exact.RatString())
+			}
+			sum = id.New().Sum(sum, part.id)
+		}
+		if !sum.Equal(original.id) {
+			t.Fatalf("ForkWeighted(%v) of %s returned parts summing up to %s", weights, original, sum)
+		}
+	}
+}
+
+func TestStampForkNBalanced(t *testing.T) {
+	stamps, _ := NewStamp().ForkN(16)
+	for _, s := range stamps {
+		if s.id.Fraction().Cmp(big.NewRat(1, 16)) != 0 {
+			t.Errorf("ForkN(16) returned %s", s)
+		}
+	}
+}
+
+func TestStampForkInvalid(t *testing.T) {
+	s := NewStamp()
+	s.Fork()
+	original := s.Clone()
+	if stamps, err := s.ForkN(0); err != id.ErrInvalidWeights || stamps != nil {
+		t.Errorf("ForkN(0) = %v, %v - expected %v", stamps, err, id.ErrInvalidWeights)
+	}
+	if stamps, err := s.ForkN(-1); err != id.ErrInvalidWeights || stamps != nil {
+		t.Errorf("ForkN(-1) = %v, %v - expected %v", stamps, err, id.ErrInvalidWeights)
+	}
+	for _, weights := range [][]int{nil, {0, 0}, {2, -1}, {math.MaxInt, math.MaxInt, 3}, {math.MaxInt / 4, 1}} {
+		if stamps, err := s.ForkWeighted(weights...); err != id.ErrInvalidWeights || stamps != nil {
+			t.Errorf("ForkWeighted(%v) = %v, %v - expected %v", weights, stamps, err, id.ErrInvalidWeights)
+		}
+	}
+	if s.String() != original.String() {
+		t.Errorf("failed forks changed %s to %s", original, s)
+	}
+}
+
+func ExampleRebalance() {
+	a := NewStamp()
+	b := a.Fork()
+	c := b.Fork()
+	d := a.Fork()
+	b.Event()
+	d.Event()
+	a.Join(c)
+	fmt.Printf("before: %s %s %s\n", a, b, d)
+	stamps, _ := Rebalance(a, b, d)
+	fmt.Printf("after: %s %s %s\n", stamps[0], stamps[1], stamps[2])
+	// Output:
+	// before: (((1, 0), (0, 1)), 0) ((0, (1, 0)), (0, 0, (0, 1, 0))) (((0, 1), 0), (0, (0, 0, 1), 0))
+	// after: ((1, 0), (0, (0, 0, 1), 0)) ((0, (1, 0)), (0, 0, (0, 1, 0))) ((0, (0, 1)), (0, (0, 0, 1), 0))
+}
+
+// churn returns the stamps of up to 10 replicas after random forks, joins and events.
+func churn(r *rand.Rand) []*Stamp {
+	stamps := []*Stamp{NewStamp()}
+	for step := 0; step < 300; step++ {
+		a, b := r.Intn(len(stamps)), r.Intn(len(stamps))
+		switch r.Intn(3) {
+		case 0:
+			if len(stamps) < 10 {
+				stamps = append(stamps, stamps[a].Fork())
+			}
+		case 1:
+			if a != b {
+				stamps[a].Join(stamps[b])
+				stamps = append(stamps[:b], stamps[b+1:]...)
+			}
+		default:
+			stamps[a].Event()
+		}
+	}
+	return stamps
+}
+
+// footprint returns the summed depth of the IDs and the summed size of the binary form of the stamps.
+func footprint(stamps []*Stamp) (depth, size int) {
+	for _, s := range stamps {
+		data, _ := s.MarshalBinary()
+		depth, size = depth+s.id.Depth(), size+len(data)
+	}
+	return depth, size
+}
+
+func TestRebalanceShrinks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	shrunk := 0
+	for n := 0; n < 100; n++ {
+		stamps := churn(r)
+		rebalanced, err := Rebalance(stamps...)
+		if err != nil {
+			t.Fatalf("Rebalance(%v) returned %v", stamps, err)
+		}
+		depth, size := footprint(stamps)
+		rebalancedDepth, rebalancedSize := footprint(rebalanced)
+		if rebalancedDepth > depth || rebalancedSize > size {
+			t.Fatalf("Rebalance(%v) = %v grew from depth %d and %d bytes to depth %d and %d bytes",
+				stamps, rebalanced, depth, size, rebalancedDepth, rebalancedSize)
+		}
+		if rebalancedSize < size {
+			shrunk++
+		}
+		seen := NewStamp().Peek()
+		for _, s := range stamps {
+			seen.Join(s.Peek())
+		}
+		union, sum := id.NewWithValue(0), id.NewWithValue(0)
+		for k, s := range rebalanced {
+			if s.Validate() != nil || id.Overlaps(sum, s.id) || !stamps[k].LEQ(s) || s.id.Fraction().Cmp(stamps[k].id.Fraction()) != 0 {
+				t.Fatalf("Rebalance(%v) = %v does not replace %s", stamps, rebalanced, stamps[k])
+			}
+			if next, err := s.WithEvent(); err == nil && next.LEQ(seen) {
+				t.Fatalf("Rebalance(%v) = %v records an event seen before with %s", stamps, rebalanced, s)
+			}
+			union, sum = id.New().Sum(union, stamps[k].id), id.New().Sum(sum, s.id)
+		}
+		if !sum.Equal(union) {
+			t.Fatalf("Rebalance(%v) = %v does not own the union %s", stamps, rebalanced, union)
+		}
+		if again, _ := Rebalance(rebalanced...); fmt.Sprint(again) != fmt.Sprint(rebalanced) {
+			t.Fatalf("Rebalance(%v) = %v is not stable but becomes %v", stamps, rebalanced, again)
+		}
+	}
+	// most IDs are about as shallow as their fractions allow already, but not all
+	if shrunk < 10 {
+		t.Errorf("Rebalance shrunk the stamps of only %d of 100 replica sets", shrunk)
+	}
+}
+
+func TestRebalanceOverlapping(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	if _, err := Rebalance(a, b, a); err != ErrOverlappingIDs {
+		t.Errorf("Rebalance of overlapping stamps returned %v - expected %v", err, ErrOverlappingIDs)
+	}
+	if stamps, err := Rebalance(); err != nil || len(stamps) != 0 {
+		t.Errorf("Rebalance() = %v, %v - expected no stamps", stamps, err)
+	}
+}
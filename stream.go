@@ -0,0 +1,40 @@
+package itc
+
+import (
+	"github.com/fgrid/itc/bit"
+	"io"
+)
+
+// WriteTo writes the binary form of the stamp s (as created by MarshalBinary without envelope)
+// directly to w and returns the number of bytes written. The encoding is padded to a byte boundary,
+// so that stamps can be written back-to-back and read again with ReadFrom.
+func (s *Stamp) WriteTo(w io.Writer) (int64, error) {
+	bw := bit.NewWriter(w)
+	s.Pack(bw)
+	err := bw.Flush()
+	return bw.Count(), err
+}
+
+// ReadFrom reads exactly one stamp written by WriteTo from r into s and returns the number of bytes
+// read. Unlike other io.ReaderFrom implementations it does not read until the end of r, so that the
+// stamps of a sequence are read by calling it repeatedly; io.EOF is returned once r ends before the
+// next stamp and bit.ErrTruncated if it ends within one. The decoded stamp is validated like by
+// UnmarshalBinary and s is left unchanged on error.
+//
+// If r implements io.ByteReader (e.g. bufio.Reader), no byte beyond the stamp is consumed.
+func (s *Stamp) ReadFrom(r io.Reader) (int64, error) {
+	br := bit.NewReader(r)
+	decoded := &Stamp{}
+	err := decoded.UnPack(br)
+	if err == io.EOF && br.Count() > 0 {
+		err = bit.ErrTruncated
+	}
+	if err != nil {
+		return br.Count(), err
+	}
+	if err := decoded.Validate(); err != nil {
+		return br.Count(), err
+	}
+	*s = *decoded
+	return br.Count(), nil
+}
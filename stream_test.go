@@ -0,0 +1,70 @@
+package itc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/fgrid/itc/bit"
+	"io"
+	"testing"
+)
+
+func ExampleStamp_WriteTo() {
+	a := NewStamp()
+	b := a.Fork()
+	a.Event()
+	var buf bytes.Buffer
+	a.WriteTo(&buf)
+	b.WriteTo(&buf)
+	for {
+		s := &Stamp{}
+		if _, err := s.ReadFrom(&buf); err != nil {
+			break
+		}
+		fmt.Println(s)
+	}
+	// Output:
+	// ((1, 0), (0, 1, 0))
+	// ((0, 1), 0)
+}
+
+func TestStampWriteToReadFrom(t *testing.T) {
+	a := NewStamp()
+	b := a.Fork()
+	c := b.Fork()
+	a.Event()
+	b.Event()
+	b.Event()
+	a.Join(b)
+	stamps := []*Stamp{a, c, NewStamp(), a.Peek()}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	for _, s := range stamps {
+		data, _ := s.MarshalBinary()
+		n, err := s.WriteTo(w)
+		if err != nil || n != int64(len(data)) {
+			t.Fatalf("WriteTo() = %d, %v - expected %d", n, err, len(data))
+		}
+	}
+	w.Flush()
+	r := bufio.NewReader(&buf)
+	for _, s := range stamps {
+		read := &Stamp{}
+		if _, err := read.ReadFrom(r); err != nil || !read.Equal(s) {
+			t.Errorf("ReadFrom() = %s, %v - expected %s", read, err, s)
+		}
+	}
+	if _, err := (&Stamp{}).ReadFrom(r); err != io.EOF {
+		t.Errorf("ReadFrom() at end of stream = %v - expected %v", err, io.EOF)
+	}
+}
+
+func TestStampReadFromTruncated(t *testing.T) {
+	s := NewStamp()
+	if _, err := s.ReadFrom(bytes.NewReader([]byte{0x8c})); err != bit.ErrTruncated {
+		t.Errorf("ReadFrom() = %v - expected %v", err, bit.ErrTruncated)
+	}
+	if s.String() != "(1, 0)" {
+		t.Errorf("ReadFrom() changed stamp to %s on error", s)
+	}
+}